@@ -0,0 +1,331 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/api"
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultDishAddr is the dish's address on the Starlink LAN
+const defaultDishAddr = "192.168.100.1:9200"
+
+// subcommands run instead of the exporter when named as the first argument.
+// Each returns the process exit code.
+var subcommands = map[string]func(args []string) int{
+	"healthcheck": runHealthcheck,
+	"status":      runStatus,
+	"history":     runHistory,
+	"alerts":      runAlerts,
+	"dump":        runDump,
+}
+
+var protojsonOptions = protojson.MarshalOptions{Multiline: true, Indent: "  "}
+
+// dialDish parses a subcommand's flags, adding -dish, and connects to the dish
+func dialDish(fs *flag.FlagSet, args []string) (*client.NativeGRPCClient, error) {
+	addr := fs.String("dish", defaultDishAddr, "Starlink dish gRPC address")
+	fs.Parse(args)
+	return client.NewNativeGRPCClient(*addr)
+}
+
+// printProto prints m as protojson
+func printProto(m proto.Message) int {
+	data, err := protojsonOptions.Marshal(m)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode response: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
+
+// handleNamed sends an empty request of the named type
+func handleNamed(c client.RawClient, name string) (*pb.Response, error) {
+	req, err := client.NewRequest(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Handle(req)
+}
+
+// runStatus implements the status subcommand, printing the dish status as a table
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Print the get_status response as protojson")
+	c, err := dialDish(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "status failed: %v\n", err)
+		return 1
+	}
+	defer c.Close()
+
+	if *asJSON {
+		resp, err := handleNamed(c, "get_status")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "status failed: %v\n", err)
+			return 1
+		}
+		return printProto(resp)
+	}
+
+	status, err := c.GetStatus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "status failed: %v\n", err)
+		return 1
+	}
+
+	alerts := "none"
+	if len(status.Alerts) > 0 {
+		alerts = strings.Join(status.Alerts, ", ")
+	}
+	gps := "no fix"
+	if status.GPSStats.GPSValid {
+		gps = "valid"
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, row := range [][2]string{
+		{"ID", status.DeviceInfo.ID},
+		{"Hardware", status.DeviceInfo.HardwareVersion},
+		{"Software", status.DeviceInfo.SoftwareVersion},
+		{"Country", status.DeviceInfo.CountryCode},
+		{"Boot count", fmt.Sprint(status.DeviceInfo.BootCount)},
+		{"Uptime", (time.Duration(status.DeviceState.UptimeS) * time.Second).String()},
+		{"Downlink", formatValue(status.DownlinkThroughputBps/1e6, 1, " Mbps")},
+		{"Uplink", formatValue(status.UplinkThroughputBps/1e6, 1, " Mbps")},
+		{"Latency", formatValue(status.PopPingLatencyMs, 1, " ms")},
+		{"Obstructed", formatValue(status.ObstructionStats.FractionObstructed*100, 2, " %")},
+		{"Pointing", formatValue(status.BoresightAzimuthDeg, 1, "° az") + ", " + formatValue(status.BoresightElevationDeg, 1, "° el")},
+		{"GPS", fmt.Sprintf("%s, %d satellites", gps, status.GPSStats.GPSSats)},
+		{"Ethernet", fmt.Sprintf("%d Mbps", status.EthSpeedMbps)},
+		{"SNR above noise floor", fmt.Sprint(status.IsSnrAboveNoiseFloor)},
+		{"Alerts", alerts},
+	} {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	tw.Flush()
+	return 0
+}
+
+// runHistory implements the history subcommand, printing recent per-second
+// samples as a table
+func runHistory(args []string) int {
+	if len(args) > 0 && args[0] == "export" {
+		return runHistoryExport(args[1:])
+	}
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	since := fs.Duration("since", 5*time.Minute, "How far back to print (the dish keeps 15 minutes; 0 prints everything)")
+	asJSON := fs.Bool("json", false, "Print the whole get_history response as protojson")
+	c, err := dialDish(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history failed: %v\n", err)
+		return 1
+	}
+	defer c.Close()
+
+	if *asJSON {
+		resp, err := handleNamed(c, "get_history")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "history failed: %v\n", err)
+			return 1
+		}
+		return printProto(resp)
+	}
+
+	history, err := c.GetHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history failed: %v\n", err)
+		return 1
+	}
+	now := time.Now().Truncate(time.Second)
+	samples, err := collector.Chronological(history, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history failed: %v\n", err)
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "TIME\tDOWN Mbps\tUP Mbps\tLATENCY ms\tDROP %\tPOWER W\t")
+	for _, s := range api.SamplesSince(samples, now, *since) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			s.Time.Format("15:04:05"),
+			formatValue(s.DownlinkThroughputBps/1e6, 2, ""),
+			formatValue(s.UplinkThroughputBps/1e6, 2, ""),
+			formatValue(s.PopPingLatencyMs, 1, ""),
+			formatValue(s.PopPingDropRate*100, 0, ""),
+			formatValue(s.PowerInWatts, 1, ""))
+	}
+	tw.Flush()
+	return 0
+}
+
+// runHistoryExport implements history export, writing the history buffer as
+// CSV or NDJSON with absolute timestamps, e.g. to keep it for a post-mortem
+func runHistoryExport(args []string) int {
+	fs := flag.NewFlagSet("history export", flag.ExitOnError)
+	format := fs.String("format", "csv", "Output format: "+strings.Join(api.HistoryFormats, " or "))
+	output := fs.String("o", "", "Write to this file instead of stdout")
+	since := fs.Duration("since", 0, "How far back to export (0 exports all 15 minutes)")
+	c, err := dialDish(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history export failed: %v\n", err)
+		return 1
+	}
+	defer c.Close()
+	if !slices.Contains(api.HistoryFormats, *format) {
+		fmt.Fprintf(os.Stderr, "unknown format %q (want %s)\n", *format, strings.Join(api.HistoryFormats, " or "))
+		return 2
+	}
+
+	history, err := c.GetHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history export failed: %v\n", err)
+		return 1
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	samples, err := collector.Chronological(history, now)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history export failed: %v\n", err)
+		return 1
+	}
+	samples = api.SamplesSince(samples, now, *since)
+
+	if *output == "" {
+		if err := api.WriteHistory(os.Stdout, *format, samples); err != nil {
+			fmt.Fprintf(os.Stderr, "history export failed: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history export failed: %v\n", err)
+		return 1
+	}
+	err = api.WriteHistory(f, *format, samples)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "history export failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d samples to %s\n", len(samples), *output)
+	return 0
+}
+
+// runAlerts implements the alerts subcommand, printing the active dish alerts
+func runAlerts(args []string) int {
+	fs := flag.NewFlagSet("alerts", flag.ExitOnError)
+	all := fs.Bool("all", false, "Print every known alert with its state")
+	asJSON := fs.Bool("json", false, "Print the alerts message as protojson")
+	c, err := dialDish(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alerts failed: %v\n", err)
+		return 1
+	}
+	defer c.Close()
+
+	if *asJSON {
+		resp, err := handleNamed(c, "get_status")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "alerts failed: %v\n", err)
+			return 1
+		}
+		return printProto(resp.GetDishGetStatus().GetAlerts())
+	}
+
+	status, err := c.GetStatus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alerts failed: %v\n", err)
+		return 1
+	}
+
+	if *all {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, name := range client.AlertNames() {
+			state := "-"
+			if slices.Contains(status.Alerts, name) {
+				state = "ACTIVE"
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", name, state)
+		}
+		tw.Flush()
+		return 0
+	}
+
+	if len(status.Alerts) == 0 {
+		fmt.Println("No active alerts")
+	}
+	for _, name := range status.Alerts {
+		fmt.Println(name)
+	}
+	return 0
+}
+
+// runDump implements the dump subcommand, printing the response to any
+// read-only request as protojson
+func runDump(args []string) int {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	name := fs.String("request", "get_status", "Request to send, e.g. get_history or dish_get_obstruction_map")
+	list := fs.Bool("list", false, "List the requests -request accepts")
+	c, err := dialDish(fs, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dump failed: %v\n", err)
+		return 1
+	}
+	defer c.Close()
+
+	if *list {
+		for _, n := range client.RequestNames() {
+			if readOnlyRequest(n) {
+				fmt.Println(n)
+			}
+		}
+		return 0
+	}
+
+	req, err := client.NewRequest(*name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v (see -list)\n", err)
+		return 2
+	}
+	// Requests like reboot or dish_stow change the dish; grpcurl is the tool for those
+	if !readOnlyRequest(*name) {
+		fmt.Fprintf(os.Stderr, "dump only sends read-only get requests, not %q (see -list)\n", *name)
+		return 2
+	}
+	resp, err := c.Handle(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dump failed: %v\n", err)
+		return 1
+	}
+	return printProto(resp)
+}
+
+// readOnlyRequest reports whether a request type only reads state, going by
+// the get_ naming convention (get_status, dish_get_obstruction_map, ...)
+func readOnlyRequest(name string) bool {
+	return strings.HasPrefix(name, "get_") || strings.Contains(name, "_get_")
+}
+
+// formatValue formats v with prec decimals followed by unit, or "-" if the
+// dish didn't measure it
+func formatValue(v float64, prec int, unit string) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "-"
+	}
+	return fmt.Sprintf("%.*f%s", prec, v, unit)
+}
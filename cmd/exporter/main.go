@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -11,35 +13,52 @@ import (
 	"time"
 
 	"github.com/R167/starlink_exporter/internal/client"
-	"github.com/R167/starlink_exporter/internal/collector"
+	"github.com/R167/starlink_exporter/internal/config"
+	"github.com/R167/starlink_exporter/internal/dashboard"
+	"github.com/R167/starlink_exporter/internal/influx"
+	"github.com/R167/starlink_exporter/internal/mqtt"
+	"github.com/R167/starlink_exporter/internal/otlp"
+	"github.com/R167/starlink_exporter/internal/remotewrite"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
 var (
-	listenAddr = flag.String("listen", ":9999", "Address to listen on for metrics")
-	dishAddr   = flag.String("dish", "192.168.100.1:9200", "Starlink dish gRPC address")
-	logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	configFile       = flag.String("config", "", "Path to a YAML config file (reloaded on SIGHUP or when it changes)")
+	listenAddr       = flag.String("listen", ":9999", "Address to listen on for metrics")
+	dishAddr         = flag.String("dish", defaultDishAddr, "Starlink dish gRPC address")
+	routerAddr       = flag.String("router", "", "Starlink router gRPC address, e.g. 192.168.1.1:9000 (disabled if empty)")
+	grpcStream       = flag.Bool("grpc-stream", true, "Multiplex requests over one Device.Stream per target (falls back to unary calls)")
+	grpcReflection   = flag.Bool("grpc-reflection", false, "Fetch each device's schema over gRPC reflection, reporting and adapting to differences from the compiled-in protos")
+	logUnknownFields = flag.Bool("log-unknown-fields", false, "Log a hex dump of the first occurrence of each response field the compiled-in protos don't know")
+	statusMaxAge     = flag.Duration("status-max-age", 5*time.Second, "How long scrapes reuse a polled get_status response (0 fetches on every scrape)")
+	meshEnable       = flag.Bool("mesh", false, "Enable mesh node and backhaul metrics (requires --router; same as --collector.mesh)")
+	selfTestInterval = flag.Duration("self-test-interval", 24*time.Hour, "Interval between scheduled dish self-tests (0 disables scheduled runs)")
+	logLevel         = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	collectDish      = flag.Bool("collector.dish", true, "Enable the dish collector (status gauges and history counters)")
+	collectRouter    = flag.Bool("collector.router", true, "Enable the router collector (requires --router)")
+	collectMesh      = flag.Bool("collector.mesh", false, "Enable the mesh collector (requires --router)")
+	collectSelfTest  = flag.Bool("collector.self_test", true, "Enable scheduled and on-demand dish self-tests")
+	collectFields    = flag.Bool("collector.status_fields", false, "Enable a gauge for every numeric, bool and enum get_status field")
+	webConfigFile    = flag.String("web.config.file", "", "Path to a web config file enabling TLS and/or basic auth (exporter-toolkit format)")
+	recordFile       = flag.String("record", "", "Append every dish response to this file for later --replay")
+	replayFile       = flag.String("replay", "", "Answer dish requests from a --record file instead of the dish")
+	replaySpeed      = flag.Float64("replay-speed", 1, "Playback speed for --replay (1 = real time, 0 = one recorded response per poll)")
 )
 
 func main() {
-	flag.Parse()
-
-	// Setup structured logging
-	var level slog.Level
-	switch *logLevel {
-	case "debug":
-		level = slog.LevelDebug
-	case "info":
-		level = slog.LevelInfo
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
 	}
 
+	flag.Parse()
+
+	// Setup structured logging; the level can change on reload
+	level := new(slog.LevelVar)
+	level.Set(parseLevel(*logLevel))
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: level,
 	}))
@@ -48,46 +67,108 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Create native gRPC client
-	grpcClient, err := client.NewNativeGRPCClient(*dishAddr)
+	// Self-metrics outlive individual clients and pushers, so they're registered once
+	client.MustRegisterMetrics(prometheus.DefaultRegisterer)
+	remotewrite.MustRegisterMetrics(prometheus.DefaultRegisterer)
+	otlp.MustRegisterMetrics(prometheus.DefaultRegisterer)
+	influx.MustRegisterMetrics(prometheus.DefaultRegisterer)
+	mqtt.MustRegisterMetrics(prometheus.DefaultRegisterer)
+
+	traffic, err := dishTrafficFromFlags(logger)
 	if err != nil {
-		logger.Error("Failed to create gRPC client", "error", err)
+		logger.Error("Invalid record/replay flags", "error", err)
 		os.Exit(1)
 	}
-	defer grpcClient.Close()
+	if traffic.recorder != nil {
+		defer traffic.recorder.Close()
+	}
 
-	// Create and start bandwidth tracker
-	bandwidthTracker := collector.NewBandwidthTracker(grpcClient, logger)
-	go bandwidthTracker.Start(ctx)
+	exp := &exporter{
+		configPath: *configFile,
+		base:       flagConfig(logger),
+		level:      level,
+		logger:     logger,
+		startTime:  time.Now(),
+		traffic:    traffic,
+	}
+	if err := exp.reload(ctx); err != nil {
+		logger.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	cfg := exp.current.Load().cfg
+
+	// Certificates and users are re-read per connection, so only validate once here
+	if err := web.Validate(cfg.HTTP.WebConfigFile); err != nil {
+		logger.Error("Invalid web config", "path", cfg.HTTP.WebConfigFile, "error", err)
+		os.Exit(1)
+	}
 
-	// Create and register Starlink collector
-	starlinkCollector := collector.NewStarlinkCollector(grpcClient, bandwidthTracker, logger)
-	prometheus.MustRegister(starlinkCollector)
+	if exp.configPath != "" {
+		go exp.watchConfig(ctx)
+	}
 
 	// Setup HTTP server with timeouts
-	http.Handle("/metrics", promhttp.Handler())
+	if cfg.HTTP.DisableMetrics {
+		logger.Info("/metrics is disabled, metrics are only pushed")
+	} else {
+		http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+			prometheus.DefaultRegisterer,
+			http.HandlerFunc(exp.metricsHandler),
+		))
+	}
+	http.HandleFunc("/selftest", exp.selfTestHandler)
+	http.HandleFunc("/diagnostics", exp.diagnosticsHandler)
+	http.HandleFunc("/api/v1/", exp.apiHandler)
+	http.HandleFunc("GET /history.csv", exp.apiHandler)
+	http.HandleFunc("GET /history.ndjson", exp.apiHandler)
+	dash := dashboard.NewHandler()
+	http.Handle("GET /{$}", dash)
+	http.Handle("GET /dashboard/", dash)
+	http.HandleFunc("/healthz", exp.healthzHandler)
+	http.HandleFunc("/readyz", exp.readyzHandler)
 	server := &http.Server{
-		Addr:         *listenAddr,
+		Addr:         cfg.HTTP.Listen,
 		Handler:      nil,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
+	// Event streams never go idle, so end them or Shutdown waits out its timeout
+	server.RegisterOnShutdown(func() {
+		if api := exp.current.Load().api; api != nil {
+			api.Stop()
+		}
+	})
+
+	systemdSocket := false
+	webFlags := &web.FlagConfig{
+		WebListenAddresses: &[]string{cfg.HTTP.Listen},
+		WebSystemdSocket:   &systemdSocket,
+		WebConfigFile:      &cfg.HTTP.WebConfigFile,
 	}
 
 	// Start HTTP server in goroutine
 	go func() {
-		logger.Info("Starting Starlink exporter", "address", *listenAddr, "dish", *dishAddr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("Starting Starlink exporter", "address", cfg.HTTP.Listen, "dish", cfg.Targets.Dish)
+		if err := web.ListenAndServe(server, webFlags, logger); err != nil && err != http.ErrServerClosed {
 			logger.Error("HTTP server error", "error", err)
 			cancel() // Cancel context before exit
 			os.Exit(1)
 		}
 	}()
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading the config on SIGHUP
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		logger.Info("SIGHUP received, reloading config")
+		if err := exp.reload(ctx); err != nil {
+			logger.Error("Failed to reload config", "error", err)
+		}
+	}
 
 	logger.Info("Shutdown signal received, stopping gracefully...")
 
@@ -99,9 +180,74 @@ func main() {
 		logger.Error("HTTP server shutdown error", "error", err)
 	}
 
-	// Stop bandwidth tracker
+	// Stop trackers and close gRPC connections
 	cancel()
-	bandwidthTracker.Stop()
+	current := exp.current.Load()
+	current.stop()
+	current.close()
 
 	logger.Info("Exporter stopped")
 }
+
+// flagConfig builds the base configuration from command-line flags
+func flagConfig(logger *slog.Logger) *config.Config {
+	cfg := config.Default()
+	cfg.LogLevel = *logLevel
+	cfg.Targets.Dish = *dishAddr
+	cfg.Targets.Router = *routerAddr
+	cfg.Targets.Stream = *grpcStream
+	cfg.Targets.Reflection = *grpcReflection
+	cfg.Targets.LogUnknownFields = *logUnknownFields
+	cfg.Collectors.Dish.StatusMaxAge = *statusMaxAge
+	cfg.Collectors.Dish.Enabled = *collectDish
+	cfg.Collectors.Router.Enabled = *collectRouter
+	cfg.Collectors.Mesh.Enabled = *meshEnable || *collectMesh
+	cfg.Collectors.SelfTest.Enabled = *collectSelfTest
+	cfg.Collectors.StatusFields.Enabled = *collectFields
+	cfg.Collectors.SelfTest.Interval = *selfTestInterval
+	cfg.HTTP.Listen = *listenAddr
+	cfg.HTTP.WebConfigFile = *webConfigFile
+
+	if cfg.Collectors.Mesh.Enabled && *routerAddr == "" {
+		logger.Warn("Mesh metrics require a router address, ignoring --mesh")
+		cfg.Collectors.Mesh.Enabled = false
+	}
+	return cfg
+}
+
+// dishTrafficFromFlags opens the --record file or loads the --replay file
+func dishTrafficFromFlags(logger *slog.Logger) (dishTraffic, error) {
+	var traffic dishTraffic
+	if *recordFile != "" && *replayFile != "" {
+		return traffic, errors.New("--record and --replay can't be used together")
+	}
+	if *replaySpeed < 0 {
+		return traffic, errors.New("--replay-speed must not be negative")
+	}
+
+	if *recordFile != "" {
+		recorder, err := client.NewRecorder(*recordFile)
+		if err != nil {
+			return traffic, err
+		}
+		traffic.recorder = recorder
+		logger.Info("Recording dish responses", "path", *recordFile)
+	}
+	if *replayFile != "" {
+		records, err := client.LoadRecording(*replayFile)
+		if err != nil {
+			return traffic, err
+		}
+		if len(records) == 0 {
+			return traffic, fmt.Errorf("%s holds no responses", *replayFile)
+		}
+		traffic.replay = client.NewReplayClient(records, *replaySpeed)
+		logger.Info("Replaying dish responses instead of contacting the dish",
+			"path", *replayFile,
+			"responses", len(records),
+			"from", records[0].Time,
+			"to", records[len(records)-1].Time,
+			"speed", *replaySpeed)
+	}
+	return traffic, nil
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/api"
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+	"github.com/R167/starlink_exporter/internal/config"
+	"github.com/R167/starlink_exporter/internal/diagnostics"
+	"github.com/R167/starlink_exporter/internal/influx"
+	"github.com/R167/starlink_exporter/internal/mqtt"
+	"github.com/R167/starlink_exporter/internal/otlp"
+	"github.com/R167/starlink_exporter/internal/remotewrite"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dishSource is what the dish collectors, API and diagnostics need from the
+// dish: the live client, a recording wrapper around it, or a replay
+type dishSource interface {
+	client.Client
+	client.RawClient
+}
+
+// dishTraffic holds the --record and --replay state. It outlives pipelines, so a
+// reload neither starts a new recording nor restarts a replay.
+type dishTraffic struct {
+	recorder *client.Recorder     // nil unless --record is set
+	replay   *client.ReplayClient // nil unless --replay is set
+}
+
+// pipeline is one generation of clients, trackers and collectors built from a
+// config. A reload builds a new pipeline and swaps it in while the HTTP server
+// keeps running.
+type pipeline struct {
+	cfg      *config.Config
+	registry *prometheus.Registry       // Every enabled collector
+	base     []prometheus.Collector     // Client self-metrics, included in every scrape
+	scrape   *collector.ScrapeCollector // Collectors selectable with collect[]
+	logger   *slog.Logger
+
+	dishClient   *client.NativeGRPCClient // nil if no dish target is configured or replaying
+	dish         dishSource               // dishClient, possibly recorded, or the replay; nil if no dish target
+	routerClient *client.NativeGRPCClient
+
+	bandwidthTracker *collector.BandwidthTracker     // nil if the dish collector is disabled
+	statusCache      *collector.StatusCache          // nil if the dish collector or caching is disabled
+	routerTracker    *collector.RouterHistoryTracker // nil if the router collector is disabled
+	selfTestRunner   *collector.SelfTestRunner       // nil if self-tests are disabled
+	diagnostics      http.Handler                    // nil if no dish target is configured
+	api              *api.Handler                    // nil if no dish target is configured
+	remoteWriter     *remotewrite.Writer             // nil if remote_write is disabled
+	otlpExporter     *otlp.Exporter                  // nil if OTLP export is disabled
+	influxWriter     *influx.Writer                  // nil if InfluxDB output is disabled
+	mqttPublisher    *mqtt.Publisher                 // nil if MQTT output is disabled
+
+	cancel context.CancelFunc
+}
+
+// newPipeline creates the clients and collectors for cfg. Nothing is polled
+// until start is called.
+func newPipeline(cfg *config.Config, startTime time.Time, traffic dishTraffic, logger *slog.Logger) (*pipeline, error) {
+	p := &pipeline{
+		cfg:    cfg,
+		scrape: collector.NewScrapeCollector(logger),
+		logger: logger,
+	}
+
+	var err error
+	if traffic.replay != nil {
+		p.dish = traffic.replay
+	} else if cfg.Targets.Dish != "" {
+		p.dishClient, err = client.NewNativeGRPCClient(cfg.Targets.Dish)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Targets.Stream {
+			p.dishClient.EnableStream()
+		}
+		if cfg.Targets.Reflection {
+			p.dishClient.EnableReflection(logger)
+		}
+		if cfg.Targets.LogUnknownFields {
+			p.dishClient.LogUnknownFields(logger)
+		}
+		p.base = append(p.base, p.dishClient)
+
+		p.dish = p.dishClient
+		if traffic.recorder != nil {
+			p.dish = client.NewRecordingClient(p.dishClient, traffic.recorder, logger)
+		}
+	}
+
+	if cfg.Collectors.Dish.Enabled {
+		p.bandwidthTracker = collector.NewBandwidthTracker(p.dish, logger)
+		p.bandwidthTracker.SetInterval(cfg.Collectors.Dish.Interval)
+		p.scrape.Add("dish", p.bandwidthTracker)
+
+		// Scrapes share one get_status response while it is fresh
+		var statusClient client.Client = p.dish
+		if cfg.Collectors.Dish.StatusMaxAge > 0 {
+			p.statusCache = collector.NewStatusCache(p.dish, cfg.Collectors.Dish.StatusMaxAge, logger)
+			statusClient = p.statusCache
+			p.scrape.Add("dish", p.statusCache)
+		}
+		p.scrape.Add("dish", collector.NewStarlinkCollector(statusClient, p.bandwidthTracker, logger))
+	}
+
+	// Generic gauges for the get_status fields, from the cached status if there is one
+	var statusMapper *collector.FieldMapper
+	if fields := cfg.Collectors.StatusFields; fields.Enabled && p.dish != nil {
+		var statusClient client.Client = p.dish
+		if p.statusCache != nil {
+			statusClient = p.statusCache
+		}
+		statusMapper = collector.NewFieldMapper("starlink_status_", fields.Allow, fields.Deny, fields.Rename, logger)
+		p.scrape.Add("status_fields", collector.NewStatusFieldsCollector(statusClient, statusMapper, logger))
+	}
+
+	// Scheduled runs plus on-demand via /selftest. Recordings have no self-tests.
+	if cfg.Collectors.SelfTest.Enabled && p.dishClient != nil {
+		p.selfTestRunner = collector.NewSelfTestRunner(p.dishClient, cfg.Collectors.SelfTest.Interval, logger)
+		p.scrape.Add("self_test", p.selfTestRunner)
+	}
+
+	if cfg.Targets.Router != "" && (cfg.Collectors.Router.Enabled || cfg.Collectors.Mesh.Enabled) {
+		p.routerClient, err = client.NewNativeGRPCClient(cfg.Targets.Router)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		if cfg.Targets.Stream {
+			p.routerClient.EnableStream()
+		}
+		if cfg.Targets.Reflection {
+			p.routerClient.EnableReflection(logger)
+		}
+		if cfg.Targets.LogUnknownFields {
+			p.routerClient.LogUnknownFields(logger)
+		}
+		p.base = append(p.base, p.routerClient)
+
+		if cfg.Collectors.Router.Enabled {
+			p.routerTracker = collector.NewRouterHistoryTracker(p.routerClient, logger)
+			p.routerTracker.SetInterval(cfg.Collectors.Router.Interval)
+			p.scrape.Add("router", collector.NewRouterCollector(p.routerClient, p.routerTracker, logger))
+		}
+
+		if cfg.Collectors.Mesh.Enabled {
+			p.scrape.Add("mesh", collector.NewMeshCollector(p.routerClient, logger))
+		}
+	}
+
+	// The mapper can't describe its metrics up front, so a field named like
+	// another metric would only clash when scraped
+	if statusMapper != nil {
+		statusMapper.Reserve(p.metricNames()...)
+	}
+
+	p.registry, err = p.newRegistry(p.scrape)
+	if err != nil {
+		p.close()
+		return nil, err
+	}
+
+	// Pushes the same metrics a full scrape of /metrics returns
+	if cfg.RemoteWrite.URL != "" {
+		p.remoteWriter, err = remotewrite.NewWriter(cfg.RemoteWrite, prometheus.Gatherers{prometheus.DefaultGatherer, p.registry}, logger)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+	}
+
+	// Push outputs share the cached status where there is one
+	var status client.Client
+	if p.statusCache != nil {
+		status = p.statusCache
+	} else if p.dish != nil {
+		status = p.dish
+	}
+
+	if cfg.OTLP.Endpoint != "" {
+		// Resource attributes come from the dish's DeviceInfo
+		p.otlpExporter, err = otlp.NewExporter(cfg.OTLP, prometheus.Gatherers{prometheus.DefaultGatherer, p.registry}, status, startTime, logger)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+	}
+
+	if cfg.InfluxDB.URL != "" {
+		p.influxWriter, err = influx.NewWriter(cfg.InfluxDB, cfg.Labels, status, logger)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		// Per-second samples come from the history tracker as it integrates them
+		if p.bandwidthTracker != nil {
+			p.bandwidthTracker.AddSampleHandler(p.influxWriter.AddSamples)
+		}
+	}
+
+	if cfg.MQTT.Broker != "" {
+		p.mqttPublisher = mqtt.NewPublisher(cfg.MQTT, status, logger)
+		// Power and drop rate only appear in history
+		if p.bandwidthTracker != nil {
+			p.bandwidthTracker.AddSampleHandler(p.mqttPublisher.AddSamples)
+		}
+	}
+
+	if p.dish != nil {
+		p.diagnostics = diagnostics.NewHandler(p.dish, func() any {
+			state := map[string]any{
+				"startTime":     startTime,
+				"dishAddress":   cfg.Targets.Dish,
+				"routerAddress": cfg.Targets.Router,
+			}
+			if p.bandwidthTracker != nil {
+				state["tracker"] = p.bandwidthTracker.GetState()
+			}
+			if p.selfTestRunner != nil {
+				state["selfTest"] = p.selfTestRunner.LastResult()
+			}
+			return state
+		}, logger)
+		p.api = api.NewHandler(status, p.dish, p.bandwidthTracker, logger)
+		// Event streams carry samples as the tracker integrates them
+		if p.bandwidthTracker != nil {
+			p.bandwidthTracker.AddSampleHandler(p.api.AddSamples)
+		}
+	}
+
+	return p, nil
+}
+
+// newRegistry returns a registry holding the base collectors and scrape, with the
+// configured constant labels
+func (p *pipeline) newRegistry(scrape *collector.ScrapeCollector) (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(p.cfg.Labels, registry)
+	for _, c := range p.base {
+		if err := registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	if err := registerer.Register(scrape); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// metricNames returns the names of the metrics /metrics serves besides the
+// status fields: the pipeline's collectors plus the default registry's
+// self-metrics and Go and process collectors
+func (p *pipeline) metricNames() []string {
+	names := collector.DescribedNames(append([]prometheus.Collector{p.scrape}, p.base...)...)
+	families, _ := prometheus.DefaultGatherer.Gather() // Partial results are fine
+	for _, mf := range families {
+		names = append(names, mf.GetName())
+	}
+	return names
+}
+
+// gatherer returns the registry to scrape for the collect[] names, or every
+// enabled collector if there are none
+func (p *pipeline) gatherer(names []string) (prometheus.Gatherer, error) {
+	if len(names) == 0 {
+		return p.registry, nil
+	}
+	scrape, err := p.scrape.Filter(names)
+	if err != nil {
+		return nil, err
+	}
+	return p.newRegistry(scrape)
+}
+
+// start launches the background trackers. If prev is non-nil it must already be
+// stopped; its counters are carried over so they stay monotonic across reloads.
+func (p *pipeline) start(ctx context.Context, prev *pipeline) {
+	if prev != nil {
+		p.inherit(prev)
+	}
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	if p.bandwidthTracker != nil {
+		go p.bandwidthTracker.Start(ctx)
+	}
+	if p.statusCache != nil {
+		go p.statusCache.Start(ctx)
+	}
+	if p.routerTracker != nil {
+		go p.routerTracker.Start(ctx)
+	}
+	if p.selfTestRunner != nil {
+		go p.selfTestRunner.Start(ctx)
+	}
+	if p.remoteWriter != nil {
+		go p.remoteWriter.Start(ctx)
+	}
+	if p.otlpExporter != nil {
+		go p.otlpExporter.Start(ctx)
+	}
+	if p.influxWriter != nil {
+		go p.influxWriter.Start(ctx)
+	}
+	if p.mqttPublisher != nil {
+		go p.mqttPublisher.Start(ctx)
+	}
+}
+
+// inherit copies tracker state and unsent push data from prev. Ring buffer
+// cursors and self-test results only carry over when the target address is
+// unchanged.
+func (p *pipeline) inherit(prev *pipeline) {
+	sameDish := p.cfg.Targets.Dish == prev.cfg.Targets.Dish
+	sameRouter := p.cfg.Targets.Router == prev.cfg.Targets.Router
+
+	if p.bandwidthTracker != nil && prev.bandwidthTracker != nil {
+		p.bandwidthTracker.InheritState(prev.bandwidthTracker, sameDish)
+	}
+	if p.routerTracker != nil && prev.routerTracker != nil {
+		p.routerTracker.InheritState(prev.routerTracker, sameRouter)
+	}
+	if p.selfTestRunner != nil && prev.selfTestRunner != nil && sameDish {
+		p.selfTestRunner.InheritState(prev.selfTestRunner)
+	}
+	if p.remoteWriter != nil && prev.remoteWriter != nil {
+		p.remoteWriter.InheritState(prev.remoteWriter)
+	}
+	if p.influxWriter != nil && prev.influxWriter != nil {
+		p.influxWriter.InheritState(prev.influxWriter)
+	}
+}
+
+// stop stops the background trackers and waits for them to exit
+func (p *pipeline) stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	if p.bandwidthTracker != nil {
+		p.bandwidthTracker.Stop()
+	}
+	if p.statusCache != nil {
+		p.statusCache.Stop()
+	}
+	if p.routerTracker != nil {
+		p.routerTracker.Stop()
+	}
+	if p.selfTestRunner != nil {
+		p.selfTestRunner.Stop()
+	}
+	if p.remoteWriter != nil {
+		p.remoteWriter.Stop()
+	}
+	if p.otlpExporter != nil {
+		p.otlpExporter.Stop()
+	}
+	if p.influxWriter != nil {
+		p.influxWriter.Stop()
+	}
+	if p.mqttPublisher != nil {
+		// Disconnects before the next pipeline connects with the same client ID
+		p.mqttPublisher.Stop()
+	}
+	if p.api != nil {
+		// Dashboards reconnect to the next pipeline's event streams
+		p.api.Stop()
+	}
+}
+
+// close closes the gRPC connections. Scrapes in flight on this pipeline fail.
+func (p *pipeline) close() {
+	if p.otlpExporter != nil {
+		p.otlpExporter.Close()
+	}
+	if p.dishClient != nil {
+		p.dishClient.Close()
+	}
+	if p.routerClient != nil {
+		p.routerClient.Close()
+	}
+}
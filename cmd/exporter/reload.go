@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 5 * time.Second
+
+// exporter owns the current pipeline and rebuilds it when the config changes
+type exporter struct {
+	mu         sync.Mutex // Serializes reloads
+	current    atomic.Pointer[pipeline]
+	configPath string         // Empty if running from flags only
+	base       *config.Config // Config built from flags; the file is applied on top
+	level      *slog.LevelVar
+	logger     *slog.Logger
+	startTime  time.Time
+	traffic    dishTraffic
+}
+
+// loadConfig returns the flag config with the config file (if any) applied on top
+func (e *exporter) loadConfig() (*config.Config, error) {
+	if e.configPath == "" {
+		return e.base.Clone(), e.base.Validate()
+	}
+	return config.Load(e.configPath, e.base)
+}
+
+// reload loads the config and swaps in a new pipeline. On error the running
+// pipeline is left untouched.
+func (e *exporter) reload(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cfg, err := e.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	next, err := newPipeline(cfg, e.startTime, e.traffic, e.logger)
+	if err != nil {
+		return fmt.Errorf("failed to build collectors: %v", err)
+	}
+
+	e.level.Set(parseLevel(cfg.LogLevel))
+
+	prev := e.current.Load()
+	if prev != nil {
+		if cfg.HTTP != prev.cfg.HTTP {
+			e.logger.Warn("HTTP settings changed, restart the exporter to apply them")
+		}
+		// Scrapes keep using prev until the swap; only its trackers are paused
+		prev.stop()
+	}
+	next.start(ctx, prev)
+	e.current.Store(next)
+	if prev != nil {
+		prev.close()
+	}
+	return nil
+}
+
+// metricsHandler serves the default registry (process and Go runtime metrics)
+// together with the current pipeline's collectors. Repeated collect[] query
+// parameters limit the scrape to those collectors, e.g. ?collect[]=router.
+func (e *exporter) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	gatherer, err := e.current.Load().gatherer(r.URL.Query()["collect[]"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, gatherer}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// selfTestHandler delegates to the current pipeline's self-test runner
+func (e *exporter) selfTestHandler(w http.ResponseWriter, r *http.Request) {
+	runner := e.current.Load().selfTestRunner
+	if runner == nil {
+		http.Error(w, "self-tests are disabled", http.StatusNotFound)
+		return
+	}
+	runner.ServeHTTP(w, r)
+}
+
+// diagnosticsHandler delegates to the current pipeline's diagnostics handler
+func (e *exporter) diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	handler := e.current.Load().diagnostics
+	if handler == nil {
+		http.Error(w, "no dish target configured", http.StatusNotFound)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// apiHandler delegates to the current pipeline's JSON API and history exports
+func (e *exporter) apiHandler(w http.ResponseWriter, r *http.Request) {
+	handler := e.current.Load().api
+	if handler == nil {
+		http.Error(w, "no dish target configured", http.StatusNotFound)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// watchConfig reloads the config whenever the file's size or modification time
+// changes. Polling keeps this working on bind-mounted and network filesystems
+// where inotify events are unreliable.
+func (e *exporter) watchConfig(ctx context.Context) {
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(e.configPath)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(e.configPath)
+			if err != nil {
+				// The file may be mid-replace; keep the running config
+				e.logger.Debug("Failed to stat config file", "error", err)
+				continue
+			}
+			if last != nil && info.Size() == last.Size() && info.ModTime().Equal(last.ModTime()) {
+				continue
+			}
+			last = info
+
+			e.logger.Info("Config file changed, reloading", "path", e.configPath)
+			if err := e.reload(ctx); err != nil {
+				e.logger.Error("Failed to reload config", "error", err)
+			}
+		}
+	}
+}
+
+// parseLevel converts a validated log level name to a slog.Level
+func parseLevel(name string) slog.Level {
+	switch name {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,4 @@
+// Package api serves the dish's current status, per-second history and the
+// exporter's integrated counters as JSON under /api/v1/, for scripts and
+// dashboards that don't speak gRPC or PromQL.
+package api
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/collector"
+)
+
+const (
+	// eventStatusInterval is how often event streams send the dish status
+	eventStatusInterval = 2 * time.Second
+
+	// eventHistoryInterval is how often event streams resend the whole history
+	// when there is no tracker to deliver samples as they arrive
+	eventHistoryInterval = 10 * time.Second
+
+	// eventBuffer is how many sample batches a slow stream can fall behind
+	// before batches are dropped
+	eventBuffer = 16
+)
+
+// AddSamples sends new history samples to every open event stream. It is a
+// collector.SampleHandler and never blocks.
+func (h *Handler) AddSamples(samples []collector.HistorySample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- samples:
+		default:
+			// The client will see a gap in its charts
+		}
+	}
+}
+
+// Stop ends every open event stream; browsers reconnect to the handler that
+// replaced this one (safe to call multiple times)
+func (h *Handler) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stopCh)
+	})
+}
+
+func (h *Handler) subscribe() chan []collector.HistorySample {
+	ch := make(chan []collector.HistorySample, eventBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *Handler) unsubscribe(ch chan []collector.HistorySample) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+// events streams Server-Sent Events:
+//   - history: the whole history buffer, sent first
+//   - samples: new history samples as the tracker integrates them
+//   - status: the dish status, or {"error": ...} while it is unreachable
+func (h *Handler) events(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Debug("Failed to clear write deadline for event stream", "error", err)
+	}
+
+	samples := h.subscribe()
+	defer h.unsubscribe(samples)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Don't let nginx buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v any) bool {
+		if err := writeEvent(w, event, v); err != nil {
+			h.logger.Debug("Failed to write event", "event", event, "error", err)
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	sendHistory := func() bool {
+		history, err := h.getHistory()
+		if err != nil {
+			return send("history", map[string]string{"error": err.Error()})
+		}
+		return send("history", history)
+	}
+	sendStatus := func() bool {
+		status, err := h.client.GetStatus()
+		if err != nil {
+			return send("status", map[string]string{"error": "failed to get status: " + err.Error()})
+		}
+		return send("status", newStatusResponse(status))
+	}
+
+	if !sendHistory() || !sendStatus() {
+		return
+	}
+
+	statusTicker := time.NewTicker(eventStatusInterval)
+	defer statusTicker.Stop()
+
+	// Without a tracker nothing arrives on samples, so poll the history instead
+	var historyTick <-chan time.Time
+	if h.tracker == nil {
+		historyTicker := time.NewTicker(eventHistoryInterval)
+		defer historyTicker.Stop()
+		historyTick = historyTicker.C
+	}
+
+	for {
+		var ok bool
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.stopCh:
+			return
+		case batch := <-samples:
+			ok = send("samples", newHistorySamples(batch))
+		case <-statusTicker.C:
+			ok = sendStatus()
+		case <-historyTick:
+			ok = sendHistory()
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// writeEvent writes one event with v as single-line JSON data
+func writeEvent(w io.Writer, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
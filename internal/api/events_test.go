@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+)
+
+// readEvent reads the next event's name and data from an SSE stream
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return event, data
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestHandler_Events(t *testing.T) {
+	h := newTestHandler(&fakeClient{
+		status: &client.StatusResponse{DeviceInfo: client.DeviceInfo{ID: "ut01234567-89abcdef"}},
+		history: &client.HistoryResponse{
+			Current:               2,
+			DownlinkThroughputBps: make([]float64, 4),
+			UplinkThroughputBps:   make([]float64, 4),
+			PowerIn:               make([]float64, 4),
+			PopPingLatencyMs:      make([]float64, 4),
+			PopPingDropRate:       make([]float64, 4),
+		},
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	r := bufio.NewReader(resp.Body)
+
+	event, data := readEvent(t, r)
+	var history historyResponse
+	if event != "history" || json.Unmarshal([]byte(data), &history) != nil || len(history.Samples) != 2 {
+		t.Fatalf("first event = %s %s, want history with 2 samples", event, data)
+	}
+	if event, data := readEvent(t, r); event != "status" || !strings.Contains(data, "ut01234567-89abcdef") {
+		t.Fatalf("second event = %s %s, want status", event, data)
+	}
+
+	// The stream subscribed before sending history; status may come first
+	h.AddSamples([]collector.HistorySample{{Time: time.Unix(1700000000, 0), DownlinkThroughputBps: 1e6}})
+	event, data = readEvent(t, r)
+	for event == "status" {
+		event, data = readEvent(t, r)
+	}
+	if event != "samples" || !strings.Contains(data, `"downlinkThroughputBps":1000000`) {
+		t.Fatalf("event = %s %s, want samples", event, data)
+	}
+
+	// Stop ends the stream so browsers reconnect elsewhere
+	h.Stop()
+	for {
+		if _, err := r.ReadString('\n'); err != nil {
+			break
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/collector"
+)
+
+// HistoryFormats are the formats WriteHistory supports
+var HistoryFormats = []string{"csv", "ndjson"}
+
+// historyContentTypes are the response content types by format
+var historyContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// historyCSVHeader names the CSV columns, one per HistorySample field
+var historyCSVHeader = []string{
+	"time",
+	"downlink_throughput_bps",
+	"uplink_throughput_bps",
+	"pop_ping_latency_ms",
+	"pop_ping_drop_rate",
+	"power_in_watts",
+}
+
+// WriteHistory writes samples to w as CSV with a header row, or as NDJSON with
+// one object per line. Times are RFC 3339; values the dish couldn't measure are
+// empty in CSV and null in NDJSON.
+func WriteHistory(w io.Writer, format string, samples []collector.HistorySample) error {
+	switch format {
+	case "csv":
+		return writeHistoryCSV(w, samples)
+	case "ndjson":
+		return writeHistoryNDJSON(w, samples)
+	default:
+		return fmt.Errorf("unknown history format %q", format)
+	}
+}
+
+func writeHistoryCSV(w io.Writer, samples []collector.HistorySample) error {
+	cw := csv.NewWriter(w)
+	cw.Write(historyCSVHeader)
+	for _, s := range samples {
+		cw.Write([]string{
+			s.Time.Format(time.RFC3339),
+			csvFloat(s.DownlinkThroughputBps),
+			csvFloat(s.UplinkThroughputBps),
+			csvFloat(s.PopPingLatencyMs),
+			csvFloat(s.PopPingDropRate),
+			csvFloat(s.PowerInWatts),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvFloat formats f for CSV, leaving non-finite values empty
+func csvFloat(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func writeHistoryNDJSON(w io.Writer, samples []collector.HistorySample) error {
+	enc := json.NewEncoder(w)
+	for _, s := range newHistorySamples(samples) {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SamplesSince returns the samples taken within since of newest, or all of
+// them if since is 0
+func SamplesSince(samples []collector.HistorySample, newest time.Time, since time.Duration) []collector.HistorySample {
+	if since <= 0 {
+		return samples
+	}
+	cutoff := newest.Add(-since)
+	for i, s := range samples {
+		if s.Time.After(cutoff) {
+			return samples[i:]
+		}
+	}
+	return nil
+}
+
+// historyExport serves the history buffer in format, limited by an optional
+// since query parameter (e.g. ?since=10m)
+func (h *Handler) historyExport(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var since time.Duration
+		if s := r.URL.Query().Get("since"); s != "" {
+			var err error
+			if since, err = time.ParseDuration(s); err != nil || since < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid since %q", s))
+				return
+			}
+		}
+
+		history, err := h.client.GetHistory()
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "failed to get history: "+err.Error())
+			return
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		samples, err := collector.Chronological(history, now)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := WriteHistory(&buf, format, SamplesSince(samples, now, since)); err != nil {
+			h.logger.Error("Failed to encode history export", "format", format, "error", err)
+			writeError(w, http.StatusInternalServerError, "failed to encode history: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", historyContentTypes[format])
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="starlink-history-%s.%s"`, now.Format("20060102T150405Z"), format))
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+)
+
+// exportHistory is a wrapped buffer: counters 2, 3, 4 live at indices 2, 0, 1
+var exportHistory = &client.HistoryResponse{
+	Current:               5,
+	DownlinkThroughputBps: []float64{5, 3, 4},
+	UplinkThroughputBps:   []float64{0.5, 0.3, 0.4},
+	PowerIn:               []float64{50, 30, 40},
+	PopPingLatencyMs:      []float64{20, math.NaN(), 20},
+	PopPingDropRate:       []float64{0, 1, 0},
+}
+
+func getExport(t *testing.T, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	newTestHandler(&fakeClient{history: exportHistory}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestHandler_HistoryCSV(t *testing.T) {
+	rec := getExport(t, "/history.csv")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 4 || strings.Join(rows[0], ",") != "time,downlink_throughput_bps,uplink_throughput_bps,pop_ping_latency_ms,pop_ping_drop_rate,power_in_watts" {
+		t.Fatalf("Unexpected rows %q", rows)
+	}
+	for i, want := range [][]string{
+		{"4", "0.4", "20", "0", "40"},
+		{"5", "0.5", "20", "0", "50"},
+		{"3", "0.3", "", "1", "30"}, // NaN latency is empty
+	} {
+		if got := rows[i+1][1:]; strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("row %d = %q, want %q", i+1, got, want)
+		}
+	}
+	if _, err := time.Parse(time.RFC3339, rows[3][0]); err != nil {
+		t.Errorf("Expected an RFC 3339 time, got %q", rows[3][0])
+	}
+}
+
+func TestHandler_HistoryNDJSON(t *testing.T) {
+	rec := getExport(t, "/history.ndjson?since=2s")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var lines []map[string]any
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want the 2 samples within 2s", len(lines))
+	}
+	if lines[0]["downlinkThroughputBps"] != 5.0 || lines[1]["popPingLatencyMs"] != nil {
+		t.Errorf("Unexpected lines %v", lines)
+	}
+
+	if rec := getExport(t, "/history.ndjson?since=soon"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad since: code = %d, want 400", rec.Code)
+	}
+}
+
+func TestSamplesSince(t *testing.T) {
+	newest := time.Unix(1000, 0)
+	samples := []collector.HistorySample{{Time: newest.Add(-2 * time.Second)}, {Time: newest.Add(-time.Second)}, {Time: newest}}
+	for _, tt := range []struct {
+		since time.Duration
+		want  int
+	}{{0, 3}, {time.Second, 1}, {2 * time.Second, 2}, {time.Hour, 3}} {
+		if got := len(SamplesSince(samples, newest, tt.since)); got != tt.want {
+			t.Errorf("SamplesSince(%v) = %d samples, want %d", tt.since, got, tt.want)
+		}
+	}
+}
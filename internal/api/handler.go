@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+)
+
+// Handler serves the JSON API:
+//   - GET /api/v1/status: the dish's get_status response
+//   - GET /api/v1/history: the dish's history buffer, oldest sample first
+//   - GET /api/v1/counters: the history tracker's integrated counters
+//   - GET /api/v1/obstruction_map: the dish's obstruction map
+//   - GET /api/v1/events: a Server-Sent Events stream of history and status
+//
+// and the history buffer as downloads, one sample per row or line:
+//   - GET /history.csv
+//   - GET /history.ndjson
+type Handler struct {
+	client  client.Client
+	raw     client.RawClient
+	tracker *collector.BandwidthTracker // nil if the dish collector is disabled
+	logger  *slog.Logger
+	mux     *http.ServeMux
+
+	mu   sync.Mutex
+	subs map[chan []collector.HistorySample]struct{} // Open event streams
+
+	stopCh   chan struct{} // Closed by Stop to end event streams
+	stopOnce sync.Once
+}
+
+// NewHandler creates an API handler for the dish behind c, with raw used for
+// requests the typed client doesn't cover. tracker may be nil, in which case
+// /api/v1/counters returns 404 and event streams only carry status.
+func NewHandler(c client.Client, raw client.RawClient, tracker *collector.BandwidthTracker, logger *slog.Logger) *Handler {
+	h := &Handler{
+		client:  c,
+		raw:     raw,
+		tracker: tracker,
+		logger:  logger,
+		mux:     http.NewServeMux(),
+		subs:    make(map[chan []collector.HistorySample]struct{}),
+		stopCh:  make(chan struct{}),
+	}
+	h.mux.HandleFunc("GET /api/v1/status", h.status)
+	h.mux.HandleFunc("GET /api/v1/history", h.history)
+	h.mux.HandleFunc("GET /api/v1/counters", h.counters)
+	h.mux.HandleFunc("GET /api/v1/obstruction_map", h.obstructionMap)
+	h.mux.HandleFunc("GET /api/v1/events", h.events)
+	h.mux.HandleFunc("GET /history.csv", h.historyExport("csv"))
+	h.mux.HandleFunc("GET /history.ndjson", h.historyExport("ndjson"))
+	h.mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "unknown API endpoint "+r.URL.Path)
+	})
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// number is a float that encodes as null when it isn't finite, as the dish
+// reports NaN for values it couldn't measure
+type number float64
+
+// MarshalJSON implements json.Marshaler
+func (n number) MarshalJSON() ([]byte, error) {
+	f := float64(n)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f)
+}
+
+// statusResponse is client.StatusResponse with its floats made JSON-safe. The
+// outer fields shadow the embedded ones with the same JSON names.
+type statusResponse struct {
+	*client.StatusResponse
+	ObstructionStats      obstructionStats `json:"obstructionStats"`
+	DownlinkThroughputBps number           `json:"downlinkThroughputBps"`
+	UplinkThroughputBps   number           `json:"uplinkThroughputBps"`
+	PopPingLatencyMs      number           `json:"popPingLatencyMs"`
+	BoresightAzimuthDeg   number           `json:"boresightAzimuthDeg"`
+	BoresightElevationDeg number           `json:"boresightElevationDeg"`
+}
+
+type obstructionStats struct {
+	FractionObstructed number `json:"fractionObstructed"`
+	ValidS             number `json:"validS"`
+	TimeObstructed     number `json:"timeObstructed"`
+}
+
+func (h *Handler) status(w http.ResponseWriter, r *http.Request) {
+	status, err := h.client.GetStatus()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "failed to get status: "+err.Error())
+		return
+	}
+	h.writeJSON(w, newStatusResponse(status))
+}
+
+func newStatusResponse(status *client.StatusResponse) statusResponse {
+	return statusResponse{
+		StatusResponse: status,
+		ObstructionStats: obstructionStats{
+			FractionObstructed: number(status.ObstructionStats.FractionObstructed),
+			ValidS:             number(status.ObstructionStats.ValidS),
+			TimeObstructed:     number(status.ObstructionStats.TimeObstructed),
+		},
+		DownlinkThroughputBps: number(status.DownlinkThroughputBps),
+		UplinkThroughputBps:   number(status.UplinkThroughputBps),
+		PopPingLatencyMs:      number(status.PopPingLatencyMs),
+		BoresightAzimuthDeg:   number(status.BoresightAzimuthDeg),
+		BoresightElevationDeg: number(status.BoresightElevationDeg),
+	}
+}
+
+// historySample is collector.HistorySample with JSON-safe floats
+type historySample struct {
+	Time                  time.Time `json:"time"`
+	DownlinkThroughputBps number    `json:"downlinkThroughputBps"`
+	UplinkThroughputBps   number    `json:"uplinkThroughputBps"`
+	PopPingLatencyMs      number    `json:"popPingLatencyMs"`
+	PopPingDropRate       number    `json:"popPingDropRate"`
+	PowerInWatts          number    `json:"powerInWatts"`
+}
+
+// historyResponse is the history buffer linearized into timestamped samples
+type historyResponse struct {
+	Current uint64          `json:"current"` // Counter value of the sample after the newest
+	Samples []historySample `json:"samples"`
+}
+
+func (h *Handler) history(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.getHistory()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	h.writeJSON(w, resp)
+}
+
+// getHistory fetches the history buffer and linearizes it
+func (h *Handler) getHistory() (*historyResponse, error) {
+	history, err := h.client.GetHistory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history: %v", err)
+	}
+	// The newest sample is the one the dish finished most recently
+	samples, err := collector.Chronological(history, time.Now().UTC().Truncate(time.Second))
+	if err != nil {
+		return nil, err
+	}
+	return &historyResponse{
+		Current: history.Current,
+		Samples: newHistorySamples(samples),
+	}, nil
+}
+
+func newHistorySamples(samples []collector.HistorySample) []historySample {
+	out := make([]historySample, len(samples))
+	for i, s := range samples {
+		out[i] = historySample{
+			Time:                  s.Time,
+			DownlinkThroughputBps: number(s.DownlinkThroughputBps),
+			UplinkThroughputBps:   number(s.UplinkThroughputBps),
+			PopPingLatencyMs:      number(s.PopPingLatencyMs),
+			PopPingDropRate:       number(s.PopPingDropRate),
+			PowerInWatts:          number(s.PowerInWatts),
+		}
+	}
+	return out
+}
+
+// obstructionMapResponse is the dish's obstruction map. SNR is row-major with
+// -1 for directions the dish hasn't seen; values in 0..1 are signal quality.
+type obstructionMapResponse struct {
+	NumRows        uint32   `json:"numRows"`
+	NumCols        uint32   `json:"numCols"`
+	SNR            []number `json:"snr"`
+	MaxThetaDeg    number   `json:"maxThetaDeg"`
+	ReferenceFrame string   `json:"referenceFrame"`
+}
+
+func (h *Handler) obstructionMap(w http.ResponseWriter, r *http.Request) {
+	resp, err := h.raw.Handle(&pb.Request{Request: &pb.Request_DishGetObstructionMap{DishGetObstructionMap: &pb.DishGetObstructionMapRequest{}}})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "failed to get obstruction map: "+err.Error())
+		return
+	}
+	m := resp.GetDishGetObstructionMap()
+	if m == nil {
+		writeError(w, http.StatusBadGateway, "dish returned no obstruction map")
+		return
+	}
+
+	snr := make([]number, len(m.Snr))
+	for i, v := range m.Snr {
+		snr[i] = number(v)
+	}
+	h.writeJSON(w, obstructionMapResponse{
+		NumRows:        m.NumRows,
+		NumCols:        m.NumCols,
+		SNR:            snr,
+		MaxThetaDeg:    number(m.MaxThetaDeg),
+		ReferenceFrame: m.MapReferenceFrame.String(),
+	})
+}
+
+func (h *Handler) counters(w http.ResponseWriter, r *http.Request) {
+	if h.tracker == nil {
+		writeError(w, http.StatusNotFound, "the dish collector is disabled")
+		return
+	}
+	h.writeJSON(w, h.tracker.GetState())
+}
+
+// writeJSON writes v as an indented JSON response
+func (h *Handler) writeJSON(w http.ResponseWriter, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		h.logger.Error("Failed to encode API response", "error", err)
+		writeError(w, http.StatusInternalServerError, "failed to encode response: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// writeError writes {"error": msg} with the given status code
+func writeError(w http.ResponseWriter, code int, msg string) {
+	data, _ := json.Marshal(map[string]string{"error": msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+type fakeClient struct {
+	status  *client.StatusResponse
+	history *client.HistoryResponse
+	err     error
+}
+
+func (f *fakeClient) GetStatus() (*client.StatusResponse, error)   { return f.status, f.err }
+func (f *fakeClient) GetHistory() (*client.HistoryResponse, error) { return f.history, f.err }
+
+func get(t *testing.T, h http.Handler, path string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("%s: invalid JSON %q: %v", path, rec.Body.String(), err)
+	}
+	return rec, body
+}
+
+func newTestHandler(c client.Client) *Handler {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	return NewHandler(c, nil, nil, logger)
+}
+
+func TestHandler_Status(t *testing.T) {
+	h := newTestHandler(&fakeClient{status: &client.StatusResponse{
+		DeviceInfo:            client.DeviceInfo{ID: "ut01234567-89abcdef"},
+		DownlinkThroughputBps: 1e6,
+		PopPingLatencyMs:      math.NaN(),
+		Alerts:                []string{"thermal_throttle"},
+	}})
+
+	rec, body := get(t, h, "/api/v1/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := body["downlinkThroughputBps"]; got != 1e6 {
+		t.Errorf("downlinkThroughputBps = %v, want 1e6", got)
+	}
+	if got, ok := body["popPingLatencyMs"]; !ok || got != nil {
+		t.Errorf("popPingLatencyMs = %v, want null", got)
+	}
+	if got := body["deviceInfo"].(map[string]any)["id"]; got != "ut01234567-89abcdef" {
+		t.Errorf("deviceInfo.id = %v", got)
+	}
+	if got := body["alerts"].([]any); len(got) != 1 || got[0] != "thermal_throttle" {
+		t.Errorf("alerts = %v", got)
+	}
+}
+
+func TestHandler_History(t *testing.T) {
+	h := newTestHandler(&fakeClient{history: &client.HistoryResponse{
+		Current:               5,
+		DownlinkThroughputBps: []float64{5, 3, 4},
+		UplinkThroughputBps:   make([]float64, 3),
+		PowerIn:               make([]float64, 3),
+		PopPingLatencyMs:      []float64{20, math.NaN(), 20},
+		PopPingDropRate:       make([]float64, 3),
+	}})
+
+	rec, body := get(t, h, "/api/v1/history")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	samples := body["samples"].([]any)
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(samples))
+	}
+
+	// Counters 2, 3, 4 live at indices 2, 0, 1
+	var prev time.Time
+	for i, want := range []float64{4, 5, 3} {
+		s := samples[i].(map[string]any)
+		if s["downlinkThroughputBps"] != want {
+			t.Errorf("sample %d: downlink = %v, want %v", i, s["downlinkThroughputBps"], want)
+		}
+		ts, err := time.Parse(time.RFC3339, s["time"].(string))
+		if err != nil {
+			t.Fatalf("sample %d: bad time: %v", i, err)
+		}
+		if i > 0 && ts.Sub(prev) != time.Second {
+			t.Errorf("sample %d: %v after the previous sample, want 1s", i, ts.Sub(prev))
+		}
+		prev = ts
+	}
+	if got := samples[2].(map[string]any)["popPingLatencyMs"]; got != nil {
+		t.Errorf("NaN latency = %v, want null", got)
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	h := newTestHandler(&fakeClient{err: errors.New("dish unreachable")})
+
+	rec, body := get(t, h, "/api/v1/status")
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status: code = %d, want 502", rec.Code)
+	}
+	if msg, _ := body["error"].(string); !strings.Contains(msg, "dish unreachable") {
+		t.Errorf("status: error = %q", msg)
+	}
+
+	if rec, _ := get(t, h, "/api/v1/counters"); rec.Code != http.StatusNotFound {
+		t.Errorf("counters without tracker: code = %d, want 404", rec.Code)
+	}
+	if rec, _ := get(t, h, "/api/v1/nope"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown endpoint: code = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// breakerThreshold is how many consecutive connectivity failures open the circuit
+	breakerThreshold = 3
+	// breakerCooldown is how long the circuit stays open before a probe is allowed
+	breakerCooldown = 10 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting the device while it is unreachable
+var ErrCircuitOpen = errors.New("circuit open: device unreachable")
+
+// breakerState is the state of a circuit breaker, exported as a gauge value
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // Requests flow normally
+	breakerHalfOpen                     // One probe request is in flight
+	breakerOpen                         // Requests fail fast
+)
+
+// circuitBreaker fails requests fast while a device is unreachable, so scrapes
+// don't each wait for an RPC timeout. After breakerCooldown it lets a single
+// probe through; success closes the circuit and failure reopens it.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    breakerState
+	failures int       // Consecutive connectivity failures
+	openedAt time.Time // When the circuit last opened
+}
+
+// allow reports whether a request may be sent. In the open state the first
+// caller after the cooldown becomes the probe.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// Wait for the probe's result
+		return false
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of an allowed request. Only
+// connectivity errors count as failures; any answer from the device, even an
+// error status, shows it is reachable.
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isConnectivityError(err) {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= breakerThreshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// current returns the breaker state
+func (b *circuitBreaker) current() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// isConnectivityError reports whether err means the device could not be reached
+func isConnectivityError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
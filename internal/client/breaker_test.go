@@ -0,0 +1,55 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{}
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+
+	// Stays closed below the threshold
+	for range breakerThreshold - 1 {
+		if !b.allow() {
+			t.Fatal("Expected requests to be allowed while closed")
+		}
+		b.record(unavailable)
+	}
+	if b.current() != breakerClosed {
+		t.Fatalf("Expected closed below threshold, got %v", b.current())
+	}
+
+	b.allow()
+	b.record(unavailable)
+	if b.current() != breakerOpen {
+		t.Fatalf("Expected open at threshold, got %v", b.current())
+	}
+	if b.allow() {
+		t.Error("Expected requests to fail fast while open")
+	}
+
+	// After the cooldown one probe is let through; a failure reopens
+	b.openedAt = time.Now().Add(-breakerCooldown)
+	if !b.allow() {
+		t.Fatal("Expected a probe after the cooldown")
+	}
+	if b.allow() {
+		t.Error("Expected only one probe while half-open")
+	}
+	b.record(unavailable)
+	if b.current() != breakerOpen {
+		t.Fatalf("Expected failed probe to reopen, got %v", b.current())
+	}
+
+	// A device error status still proves the device is reachable
+	b.openedAt = time.Now().Add(-breakerCooldown)
+	b.allow()
+	b.record(status.Error(codes.PermissionDenied, "unsupported request"))
+	if b.current() != breakerClosed {
+		t.Errorf("Expected successful probe to close, got %v", b.current())
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC client self-metrics. They are package-level so counts survive config
+// reloads that replace the clients; register them once with MustRegisterMetrics.
+var (
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "starlink_exporter_grpc_request_duration_seconds",
+			Help:    "Duration of gRPC requests to Starlink devices in seconds",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"target", "request"},
+	)
+	requestErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_grpc_request_errors_total",
+			Help: "Total failed gRPC requests to Starlink devices by gRPC status code",
+		},
+		[]string{"target", "request", "code"},
+	)
+	circuitRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_grpc_circuit_rejected_total",
+			Help: "Total requests failed fast without contacting the device because its circuit was open",
+		},
+		[]string{"target"},
+	)
+	streamConnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_grpc_stream_connects_total",
+			Help: "Total Device.Stream connections opened (including reconnects)",
+		},
+		[]string{"target"},
+	)
+	streamFallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_grpc_stream_fallbacks_total",
+			Help: "Total requests sent as unary calls because the stream was unavailable",
+		},
+		[]string{"target"},
+	)
+	schemaDifferences = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "starlink_exporter_schema_differences",
+			Help: "Fields whose definition on the device differs from the compiled-in protos, by kind (set once reflection succeeds)",
+		},
+		[]string{"target", "kind"},
+	)
+	unknownFields = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_unknown_fields",
+			Help: "Responses carrying a field number the compiled-in protos don't know, by message type; new firmware fields that need regenerated protos",
+		},
+		[]string{"message", "field"},
+	)
+)
+
+// MustRegisterMetrics registers the gRPC client self-metrics with reg
+func MustRegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(requestDuration, requestErrors, circuitRejected, streamConnects, streamFallbacks, schemaDifferences, unknownFields)
+}
+
+// observeRequest records the duration and outcome of one request
+func observeRequest(target, request string, seconds float64, err error) {
+	requestDuration.WithLabelValues(target, request).Observe(seconds)
+	if err != nil {
+		requestErrors.WithLabelValues(target, request, status.Code(err).String()).Inc()
+	}
+}
+
+// requestType returns the name of the request oneof field that is set, e.g. "get_status"
+func requestType(req *pb.Request) string {
+	m := req.ProtoReflect()
+	if fd := m.WhichOneof(m.Descriptor().Oneofs().ByName("request")); fd != nil {
+		return string(fd.Name())
+	}
+	return "unknown"
+}
@@ -2,18 +2,29 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 // NativeGRPCClient uses generated protobuf code for gRPC communication
 type NativeGRPCClient struct {
-	conn   *grpc.ClientConn
-	client pb.DeviceClient
+	conn       *grpc.ClientConn
+	client     pb.DeviceClient
+	address    string
+	stream     *streamTransport // nil unless EnableStream was called
+	reflection *reflectionState // nil unless EnableReflection was called
+	unknownLog *unknownFieldLog // nil unless LogUnknownFields was called
+	breaker    circuitBreaker
+
+	circuitState *prometheus.Desc
 }
 
 // NewNativeGRPCClient creates a new native gRPC client
@@ -27,31 +38,100 @@ func NewNativeGRPCClient(address string) (*NativeGRPCClient, error) {
 	}
 
 	return &NativeGRPCClient{
-		conn:   conn,
-		client: pb.NewDeviceClient(conn),
+		conn:    conn,
+		client:  pb.NewDeviceClient(conn),
+		address: address,
+
+		circuitState: prometheus.NewDesc(
+			"starlink_exporter_grpc_circuit_state",
+			"Circuit breaker state for the device (0 = closed, 1 = half-open, 2 = open: device unreachable)",
+			nil, prometheus.Labels{"target": address},
+		),
 	}, nil
 }
 
+// EnableStream sends requests over one long-lived Device.Stream instead of a
+// unary Handle call each. Requests fall back to unary calls while the stream is
+// reconnecting, or for good if the device doesn't support streaming. It must be
+// called before the client is used.
+func (c *NativeGRPCClient) EnableStream() {
+	c.stream = newStreamTransport(c.client, c.address)
+}
+
 // Close closes the gRPC connection
 func (c *NativeGRPCClient) Close() error {
+	if c.stream != nil {
+		c.stream.close()
+	}
 	return c.conn.Close()
 }
 
-// GetStatus retrieves current status from the dish
-func (c *NativeGRPCClient) GetStatus() (*StatusResponse, error) {
+// Handle sends a single request to the Device/Handle RPC
+func (c *NativeGRPCClient) Handle(req *pb.Request) (*pb.Response, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	req := &pb.Request{
-		Request: &pb.Request_GetStatus{
-			GetStatus: &pb.GetStatusRequest{},
-		},
+	if !c.breaker.allow() {
+		circuitRejected.WithLabelValues(c.address).Inc()
+		return nil, ErrCircuitOpen
 	}
 
-	resp, err := c.client.Handle(ctx, req)
+	start := time.Now()
+	resp, err := c.handle(ctx, req)
+	observeRequest(c.address, requestType(req), time.Since(start).Seconds(), err)
+	c.breaker.record(err)
 	if err != nil {
 		return nil, fmt.Errorf("rpc failed: %v", err)
 	}
+	c.checkUnknownFields(resp)
+	return resp, nil
+}
+
+// Describe implements prometheus.Collector
+func (c *NativeGRPCClient) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.circuitState
+}
+
+// Collect implements prometheus.Collector, exporting the circuit breaker state
+// so an unreachable device can be told apart from a broken exporter
+func (c *NativeGRPCClient) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.circuitState, prometheus.GaugeValue, float64(c.breaker.current()))
+}
+
+// handle sends req over the stream if enabled, falling back to a unary call.
+// Requests to a device whose schema has drifted are transcoded instead.
+func (c *NativeGRPCClient) handle(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	if c.reflection != nil {
+		if schema := c.reflection.get(ctx, c.conn); schema != nil && schema.transcode {
+			return schema.handle(ctx, c.conn, req)
+		}
+	}
+	if c.stream != nil {
+		resp, err := c.stream.handle(ctx, req)
+		if !errors.Is(err, errStreamUnavailable) {
+			return resp, err
+		}
+		streamFallbacks.WithLabelValues(c.address).Inc()
+	}
+	return c.client.Handle(ctx, req)
+}
+
+// GetStatus retrieves current status from the dish
+func (c *NativeGRPCClient) GetStatus() (*StatusResponse, error) {
+	return getStatus(c)
+}
+
+// getStatus sends get_status over c and converts the dish's response. Every
+// Client implementation shares it, so recorded and live responses decode alike.
+func getStatus(c RawClient) (*StatusResponse, error) {
+	resp, err := c.Handle(&pb.Request{
+		Request: &pb.Request_GetStatus{
+			GetStatus: &pb.GetStatusRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	dishStatus := resp.GetDishGetStatus()
 	if dishStatus == nil {
@@ -85,23 +165,56 @@ func (c *NativeGRPCClient) GetStatus() (*StatusResponse, error) {
 		},
 		EthSpeedMbps:         int(dishStatus.EthSpeedMbps),
 		IsSnrAboveNoiseFloor: dishStatus.IsSnrAboveNoiseFloor,
+		Alerts:               activeAlerts(dishStatus.Alerts),
+		Raw:                  dishStatus,
 	}, nil
 }
 
+// AlertNames returns the name of every dish alert this build knows about, in
+// the order StatusResponse.Alerts uses
+func AlertNames() []string {
+	var names []string
+	fields := (*pb.DishAlerts)(nil).ProtoReflect().Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); fd.Kind() == protoreflect.BoolKind {
+			names = append(names, string(fd.Name()))
+		}
+	}
+	return names
+}
+
+// activeAlerts returns the names of the alert flags that are set, in field
+// order. Every bool field counts, so new alerts only need regenerated protos.
+func activeAlerts(alerts *pb.DishAlerts) []string {
+	active := []string{}
+	if alerts == nil {
+		return active
+	}
+	m := alerts.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.Kind() == protoreflect.BoolKind && m.Get(fd).Bool() {
+			active = append(active, string(fd.Name()))
+		}
+	}
+	return active
+}
+
 // GetHistory retrieves historical data from the dish
 func (c *NativeGRPCClient) GetHistory() (*HistoryResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	return getHistory(c)
+}
 
-	req := &pb.Request{
+// getHistory sends get_history over c and converts the dish's response
+func getHistory(c RawClient) (*HistoryResponse, error) {
+	resp, err := c.Handle(&pb.Request{
 		Request: &pb.Request_GetHistory{
 			GetHistory: &pb.GetHistoryRequest{},
 		},
-	}
-
-	resp, err := c.client.Handle(ctx, req)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("rpc failed: %v", err)
+		return nil, err
 	}
 
 	dishHistory := resp.GetDishGetHistory()
@@ -144,3 +257,253 @@ func (c *NativeGRPCClient) GetHistory() (*HistoryResponse, error) {
 		PowerIn:               powerIn,
 	}, nil
 }
+
+// GetRadioStats retrieves per-band radio statistics from the router
+func (c *NativeGRPCClient) GetRadioStats() (*RadioStatsResponse, error) {
+	resp, err := c.Handle(&pb.Request{
+		Request: &pb.Request_GetRadioStats{
+			GetRadioStats: &pb.GetRadioStatsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	radioStats := resp.GetGetRadioStats()
+	if radioStats == nil {
+		return nil, fmt.Errorf("no radio stats in response")
+	}
+
+	radios := make([]RadioStats, 0, len(radioStats.RadioStats))
+	for _, r := range radioStats.RadioStats {
+		// Older firmware only reports the integer temperature
+		temp := r.GetThermalStatus().GetTemp2()
+		if temp == 0 {
+			temp = float64(r.GetThermalStatus().GetTemp())
+		}
+
+		antenna := r.GetAntennaStatus()
+		radios = append(radios, RadioStats{
+			Band: bandLabel(r.Band),
+			RxStats: RadioRxStats{
+				Bytes:       r.GetRxStats().GetBytes(),
+				Packets:     r.GetRxStats().GetPackets(),
+				FrameErrors: r.GetRxStats().GetFrameErrors(),
+			},
+			TxStats: RadioTxStats{
+				Bytes:   r.GetTxStats().GetBytes(),
+				Packets: r.GetTxStats().GetPackets(),
+			},
+			ThermalStatus: RadioThermalStatus{
+				Level:          r.GetThermalStatus().GetLevel(),
+				TempC:          temp,
+				PowerReduction: r.GetThermalStatus().GetPowerReduction(),
+				DutyCycle:      r.GetThermalStatus().GetDutyCycle(),
+			},
+			AntennaStatus: RadioAntennaStatus{
+				Rssi: []float64{
+					float64(antenna.GetRssi1()),
+					float64(antenna.GetRssi2()),
+					float64(antenna.GetRssi3()),
+					float64(antenna.GetRssi4()),
+				},
+			},
+		})
+	}
+
+	return &RadioStatsResponse{RadioStats: radios}, nil
+}
+
+// GetWifiHistory retrieves historical ping and DNS data from the router
+func (c *NativeGRPCClient) GetWifiHistory() (*WifiHistoryResponse, error) {
+	resp, err := c.Handle(&pb.Request{
+		Request: &pb.Request_GetHistory{
+			GetHistory: &pb.GetHistoryRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wifiHistory := resp.GetWifiGetHistory()
+	if wifiHistory == nil {
+		return nil, fmt.Errorf("no wifi history in response")
+	}
+
+	dnsDropRate := make(map[string][]float64, len(wifiHistory.DnsResolverDropRate))
+	for resolver, h := range wifiHistory.DnsResolverDropRate {
+		dnsDropRate[resolver] = float64s(h.GetDropRateLast_15S())
+	}
+
+	return &WifiHistoryResponse{
+		Current:             wifiHistory.Current,
+		PingDropRate:        float64s(wifiHistory.PingDropRate),
+		PingLatencyMs:       float64s(wifiHistory.PingLatencyMs),
+		CurrentIndex15s:     wifiHistory.CurrentIndex_15S,
+		DnsResolverDropRate: dnsDropRate,
+	}, nil
+}
+
+// GetMeshStatus retrieves the mesh routers (controller and repeaters) known to the router.
+// Nodes report their WifiMeshStatus to the controller, which exposes them as clients
+// with a mesh role alongside hop count and estimated link rates.
+func (c *NativeGRPCClient) GetMeshStatus() (*MeshStatusResponse, error) {
+	resp, err := c.Handle(&pb.Request{
+		Request: &pb.Request_WifiGetClients{
+			WifiGetClients: &pb.WifiGetClientsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	wifiClients := resp.GetWifiGetClients()
+	if wifiClients == nil {
+		return nil, fmt.Errorf("no wifi clients in response")
+	}
+
+	var nodes []MeshNode
+	seen := make(map[string]bool)
+	for _, cl := range wifiClients.Clients {
+		role := cl.GetRole()
+		if role != pb.WifiClient_REPEATER && role != pb.WifiClient_CONTROLLER {
+			continue
+		}
+
+		id := cl.DeviceId
+		if id == "" {
+			id = cl.MacAddress
+		}
+		if seen[id] {
+			// A node associated on more than one interface is listed once per interface
+			continue
+		}
+		seen[id] = true
+
+		nodes = append(nodes, MeshNode{
+			ID:                 id,
+			Name:               cl.Name,
+			MacAddress:         cl.MacAddress,
+			Role:               strings.ToLower(role.String()),
+			HardwareVersion:    cl.HardwareVersion,
+			SoftwareVersion:    cl.SoftwareVersion,
+			HopsFromController: cl.HopsFromController,
+			EstTxRateMbps:      float64(cl.EstTxRateMbpsFromController),
+			EstRxRateMbps:      float64(cl.EstRxRateMbpsFromController),
+			SignalStrength:     float64(cl.SignalStrength),
+		})
+	}
+
+	return &MeshStatusResponse{Nodes: nodes}, nil
+}
+
+// GetBackhaulStats retrieves the backhaul link statistics of a mesh router
+func (c *NativeGRPCClient) GetBackhaulStats() (*BackhaulStatsResponse, error) {
+	resp, err := c.Handle(&pb.Request{
+		Request: &pb.Request_WifiBackhaulStats{
+			WifiBackhaulStats: &pb.WifiBackhaulStatsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	backhaul := resp.GetWifiBackhaulStats()
+	if backhaul == nil {
+		return nil, fmt.Errorf("no backhaul stats in response")
+	}
+
+	candidates := make([]BackhaulCandidate, 0, len(backhaul.SiteSurveyScan))
+	for _, r := range backhaul.SiteSurveyScan {
+		candidates = append(candidates, BackhaulCandidate{
+			Bssid:         r.MacAddress,
+			Ssid:          r.Ssid,
+			Channel:       r.Channel,
+			Rssi:          float64(r.Rssi),
+			EstRxRateMbps: float64(r.EstRxRate),
+		})
+	}
+
+	return &BackhaulStatsResponse{
+		Success:    backhaul.Success,
+		Bssid:      backhaul.Bssid,
+		Iface:      strings.ToLower(strings.TrimPrefix(backhaul.Iface.String(), "IFACE_TYPE_")),
+		Preference: backhaul.Preference,
+		Candidates: candidates,
+	}, nil
+}
+
+// RunSelfTest runs the on-dish hardware self-test
+func (c *NativeGRPCClient) RunSelfTest() (*SelfTestResponse, error) {
+	resp, err := c.Handle(&pb.Request{
+		Request: &pb.Request_SelfTest{
+			SelfTest: &pb.SelfTestRequest{Detailed: true},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	selfTest := resp.GetSelfTest()
+	if selfTest == nil {
+		return nil, fmt.Errorf("no self test in response")
+	}
+
+	return &SelfTestResponse{
+		Passed: selfTest.Passed,
+		Report: selfTest.Report,
+	}, nil
+}
+
+// GetDiagnostics retrieves diagnostics, including the last hardware self-test result, from the dish
+func (c *NativeGRPCClient) GetDiagnostics() (*DiagnosticsResponse, error) {
+	resp, err := c.Handle(&pb.Request{
+		Request: &pb.Request_GetDiagnostics{
+			GetDiagnostics: &pb.GetDiagnosticsRequest{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := resp.GetDishGetDiagnostics()
+	if diagnostics == nil {
+		return nil, fmt.Errorf("no dish diagnostics in response")
+	}
+
+	codes := make([]string, 0, len(diagnostics.HardwareSelfTestCodes))
+	for _, code := range diagnostics.HardwareSelfTestCodes {
+		codes = append(codes, strings.ToLower(code.String()))
+	}
+
+	return &DiagnosticsResponse{
+		ID:                    diagnostics.Id,
+		HardwareVersion:       diagnostics.HardwareVersion,
+		SoftwareVersion:       diagnostics.SoftwareVersion,
+		HardwareSelfTest:      strings.ToLower(diagnostics.HardwareSelfTest.String()),
+		HardwareSelfTestCodes: codes,
+	}, nil
+}
+
+// float64s widens a float32 history array
+func float64s(values []float32) []float64 {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+// bandLabel converts a WiFi band enum into a short Prometheus-friendly label
+func bandLabel(band pb.WifiConfig_Band) string {
+	switch band {
+	case pb.WifiConfig_RF_2GHZ:
+		return "2.4ghz"
+	case pb.WifiConfig_RF_5GHZ:
+		return "5ghz"
+	case pb.WifiConfig_RF_5GHZ_HIGH:
+		return "5ghz_high"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+)
+
+func TestActiveAlerts(t *testing.T) {
+	got := activeAlerts(&pb.DishAlerts{ThermalThrottle: true, Roaming: true})
+	if strings.Join(got, ",") != "thermal_throttle,roaming" {
+		t.Errorf("Expected thermal_throttle,roaming, got %v", got)
+	}
+	if got := activeAlerts(nil); got == nil || len(got) != 0 {
+		t.Errorf("Expected an empty list for no alerts, got %#v", got)
+	}
+}
+
+func TestAlertNames(t *testing.T) {
+	names := AlertNames()
+	if len(names) < 20 || names[0] != "motors_stuck" {
+		t.Errorf("Expected every DishAlerts flag starting with motors_stuck, got %v", names)
+	}
+}
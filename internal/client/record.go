@@ -0,0 +1,272 @@
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRecordSize bounds a single record when reading, so a corrupt length
+// prefix fails instead of allocating gigabytes. A full get_history response is
+// well under 100 KiB.
+const maxRecordSize = 16 << 20
+
+// Record fields. A recording is a sequence of length-delimited (varint size
+// prefix) messages of this shape, so it can also be read with protodelim:
+//
+//	message Record {
+//	  int64 time_unix_nano = 1;
+//	  string request = 2;                      // e.g. "get_history"
+//	  SpaceX.API.Device.Response response = 3;
+//	}
+const (
+	recordTimeField     protowire.Number = 1
+	recordRequestField  protowire.Number = 2
+	recordResponseField protowire.Number = 3
+)
+
+// RecordedResponse is one response read back from a recording
+type RecordedResponse struct {
+	Time     time.Time
+	Request  string // Request oneof field name, e.g. "get_status"
+	Response *pb.Response
+}
+
+// Recorder appends timestamped responses to a recording file. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewRecorder opens path for recording, appending if it already exists so a
+// restarted exporter continues the same recording. A record cut short by a
+// crash mid-write is truncated away first; appending after it would make every
+// later record unreadable.
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %v", err)
+	}
+
+	br := bufio.NewReader(f)
+	var end int64 // Offset just past the last complete record
+	for {
+		body, err := readRecord(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read recording: %v", err)
+		}
+		end += int64(protowire.SizeVarint(uint64(len(body))) + len(body))
+	}
+	if err := f.Truncate(end); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to truncate recording: %v", err)
+	}
+	return &Recorder{f: f}, nil
+}
+
+// Record appends resp, the answer to a request of type request, received at t
+func (r *Recorder) Record(t time.Time, request string, resp *pb.Response) error {
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("failed to encode response: %v", err)
+	}
+
+	var body []byte
+	body = protowire.AppendTag(body, recordTimeField, protowire.VarintType)
+	body = protowire.AppendVarint(body, uint64(t.UnixNano()))
+	body = protowire.AppendTag(body, recordRequestField, protowire.BytesType)
+	body = protowire.AppendString(body, request)
+	body = protowire.AppendTag(body, recordResponseField, protowire.BytesType)
+	body = protowire.AppendBytes(body, data)
+
+	// One write per record, so a crash can only cut off the last one
+	buf := protowire.AppendVarint(nil, uint64(len(body)))
+	buf = append(buf, body...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.f.Write(buf); err != nil {
+		return fmt.Errorf("failed to write recording: %v", err)
+	}
+	return nil
+}
+
+// Close closes the recording file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// LoadRecording reads every response in the recording at path, in file order.
+// A record cut short by a crash mid-write is dropped.
+func LoadRecording(path string) ([]RecordedResponse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %v", err)
+	}
+	defer f.Close()
+	return ReadRecording(f)
+}
+
+// ReadRecording reads every response in a recording from r
+func ReadRecording(r io.Reader) ([]RecordedResponse, error) {
+	br := bufio.NewReader(r)
+	var records []RecordedResponse
+	for {
+		body, err := readRecord(br)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", len(records), err)
+		}
+
+		rec, err := parseRecord(body)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %v", len(records), err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// readRecord reads the next record's body from br. It returns io.EOF at the end
+// of the recording, including at a record cut short by a crash mid-write.
+func readRecord(br *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(br)
+	if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size > maxRecordSize {
+		return nil, fmt.Errorf("size %d exceeds %d bytes, not a recording?", size, maxRecordSize)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(br, body); err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// parseRecord decodes one Record message, skipping fields it doesn't know
+func parseRecord(b []byte) (RecordedResponse, error) {
+	var rec RecordedResponse
+	var data []byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return rec, protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch {
+		case num == recordTimeField && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return rec, protowire.ParseError(n)
+			}
+			rec.Time = time.Unix(0, int64(v))
+			b = b[n:]
+		case num == recordRequestField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return rec, protowire.ParseError(n)
+			}
+			rec.Request = v
+			b = b[n:]
+		case num == recordResponseField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return rec, protowire.ParseError(n)
+			}
+			data = v
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return rec, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+
+	if data == nil || rec.Request == "" {
+		return rec, errors.New("missing request or response")
+	}
+	rec.Response = &pb.Response{}
+	if err := proto.Unmarshal(data, rec.Response); err != nil {
+		return rec, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return rec, nil
+}
+
+// RecordingClient passes requests through to another client and records every
+// successful response
+type RecordingClient struct {
+	client   RawClient
+	recorder *Recorder
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	failing bool // Only the first of a run of write errors is logged as a warning
+}
+
+// NewRecordingClient wraps c, recording its responses with recorder
+func NewRecordingClient(c RawClient, recorder *Recorder, logger *slog.Logger) *RecordingClient {
+	return &RecordingClient{client: c, recorder: recorder, logger: logger}
+}
+
+// Handle sends req to the wrapped client and records the response. A failed
+// write is logged but doesn't fail the request.
+func (c *RecordingClient) Handle(req *pb.Request) (*pb.Response, error) {
+	resp, err := c.client.Handle(req)
+	if err != nil {
+		return nil, err
+	}
+
+	recErr := c.recorder.Record(time.Now(), requestType(req), resp)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case recErr != nil && !c.failing:
+		c.logger.Warn("Failed to record response", "error", recErr)
+		c.failing = true
+	case recErr != nil:
+		c.logger.Debug("Failed to record response", "error", recErr)
+	case c.failing:
+		c.logger.Info("Recording recovered")
+		c.failing = false
+	}
+	return resp, nil
+}
+
+// GetStatus retrieves current status through the wrapped client
+func (c *RecordingClient) GetStatus() (*StatusResponse, error) {
+	return getStatus(c)
+}
+
+// GetHistory retrieves historical data through the wrapped client
+func (c *RecordingClient) GetHistory() (*HistoryResponse, error) {
+	return getHistory(c)
+}
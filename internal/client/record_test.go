@@ -0,0 +1,284 @@
+package client
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+)
+
+// fakeRawClient answers every request with resp
+type fakeRawClient struct {
+	resp *pb.Response
+}
+
+func (f *fakeRawClient) Handle(req *pb.Request) (*pb.Response, error) {
+	return f.resp, nil
+}
+
+func historyResponse(current uint64) *pb.Response {
+	return &pb.Response{Response: &pb.Response_DishGetHistory{DishGetHistory: &pb.DishGetHistoryResponse{
+		Current:               current,
+		DownlinkThroughputBps: []float32{8000, 16000},
+		UplinkThroughputBps:   []float32{800, 1600},
+		PopPingLatencyMs:      []float32{20, 30},
+		PopPingDropRate:       []float32{0, 0},
+		PowerIn:               []float32{50, 60},
+	}}}
+}
+
+func statusResponse(uptime uint64) *pb.Response {
+	return &pb.Response{Response: &pb.Response_DishGetStatus{DishGetStatus: &pb.DishGetStatusResponse{
+		DeviceInfo:       &pb.DeviceInfo{Id: "ut01"},
+		DeviceState:      &pb.DeviceState{UptimeS: uptime},
+		ObstructionStats: &pb.DishObstructionStats{},
+		GpsStats:         &pb.DishGpsStats{},
+	}}}
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dish.rec")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeRawClient{resp: historyResponse(100)}
+	rc := NewRecordingClient(fake, rec, slog.Default())
+	if _, err := rc.GetHistory(); err != nil {
+		t.Fatal(err)
+	}
+	fake.resp = statusResponse(42)
+	if _, err := rc.GetStatus(); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+
+	// A second recorder appends to the same file
+	rec, err = NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Record(time.Unix(1000, 0), "get_history", historyResponse(101)); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+
+	records, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	if records[0].Request != "get_history" || records[0].Response.GetDishGetHistory().GetCurrent() != 100 {
+		t.Errorf("record 0 = %v %v", records[0].Request, records[0].Response)
+	}
+	if records[1].Request != "get_status" || records[1].Response.GetDishGetStatus().GetDeviceState().GetUptimeS() != 42 {
+		t.Errorf("record 1 = %v %v", records[1].Request, records[1].Response)
+	}
+	if !records[2].Time.Equal(time.Unix(1000, 0)) {
+		t.Errorf("record 2 time = %v, want %v", records[2].Time, time.Unix(1000, 0))
+	}
+}
+
+func TestReadRecordingTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dish.rec")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Record(time.Unix(1000, 0), "get_history", historyResponse(100))
+	rec.Record(time.Unix(1001, 0), "get_history", historyResponse(101))
+	rec.Close()
+
+	// Cut the last record short, as if the exporter died mid-write
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("got %d records, want the 1 complete record", len(records))
+	}
+
+	if err := os.WriteFile(path, []byte("\x05hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRecording(path); err == nil {
+		t.Error("expected an error for a file that isn't a recording")
+	}
+}
+
+func TestRecorderTruncatesTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dish.rec")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Record(time.Unix(1000, 0), "get_history", historyResponse(100))
+	rec.Record(time.Unix(1001, 0), "get_history", historyResponse(101))
+	rec.Close()
+
+	// Die mid-write, then restart and keep recording
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+	rec, err = NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Record(time.Unix(1002, 0), "get_history", historyResponse(102)); err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+
+	records, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	for i, want := range []uint64{100, 102} {
+		if got := records[i].Response.GetDishGetHistory().GetCurrent(); got != want {
+			t.Errorf("record %d current = %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestReplayClientStep(t *testing.T) {
+	start := time.Unix(1000, 0)
+	replay := NewReplayClient([]RecordedResponse{
+		{Time: start, Request: "get_history", Response: historyResponse(100)},
+		{Time: start.Add(500 * time.Millisecond), Request: "get_status", Response: statusResponse(1)},
+		{Time: start.Add(time.Second), Request: "get_history", Response: historyResponse(101)},
+	}, 0)
+
+	for _, want := range []uint64{100, 101} {
+		history, err := replay.GetHistory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if history.Current != want {
+			t.Errorf("Current = %d, want %d", history.Current, want)
+		}
+	}
+	if _, err := replay.GetHistory(); !errors.Is(err, ErrReplayFinished) {
+		t.Errorf("expected ErrReplayFinished after the last history, got %v", err)
+	}
+
+	// Request types are stepped through independently
+	status, err := replay.GetStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.DeviceState.UptimeS != 1 {
+		t.Errorf("UptimeS = %d, want 1", status.DeviceState.UptimeS)
+	}
+}
+
+func TestReplayClientTimed(t *testing.T) {
+	start := time.Unix(1000, 0)
+	var records []RecordedResponse
+	for i := range 10 {
+		records = append(records, RecordedResponse{
+			Time:     start.Add(time.Duration(i) * time.Second),
+			Request:  "get_history",
+			Response: historyResponse(uint64(100 + i)),
+		})
+	}
+	records = append(records, RecordedResponse{Time: start.Add(2500 * time.Millisecond), Request: "get_status", Response: statusResponse(7)})
+
+	now := time.Unix(5000, 0)
+	replay := NewReplayClient(records, 2)
+	replay.now = func() time.Time { return now }
+
+	tests := []struct {
+		elapsed time.Duration
+		current uint64
+	}{
+		{0, 100},
+		{500 * time.Millisecond, 101},  // Recording at +1s
+		{1700 * time.Millisecond, 103}, // +3.4s
+		{4500 * time.Millisecond, 109}, // +9s, the last record
+		{4600 * time.Millisecond, 0},   // Past the end
+	}
+	wall := now
+	for _, tt := range tests {
+		now = wall.Add(tt.elapsed)
+		history, err := replay.GetHistory()
+		if tt.current == 0 {
+			if !errors.Is(err, ErrReplayFinished) {
+				t.Errorf("at %v: expected ErrReplayFinished, got %v", tt.elapsed, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("at %v: %v", tt.elapsed, err)
+		}
+		if history.Current != tt.current {
+			t.Errorf("at %v: Current = %d, want %d", tt.elapsed, history.Current, tt.current)
+		}
+	}
+
+	// The first status was recorded at +2.5s
+	replay = NewReplayClient(records, 1)
+	replay.now = func() time.Time { return now }
+	now = wall
+	if _, err := replay.GetStatus(); err == nil || errors.Is(err, ErrReplayFinished) {
+		t.Errorf("expected a not-recorded-yet error before the first status, got %v", err)
+	}
+	now = wall.Add(3 * time.Second)
+	if status, err := replay.GetStatus(); err != nil || status.DeviceState.UptimeS != 7 {
+		t.Errorf("GetStatus at +3s = %v, %v", status, err)
+	}
+}
+
+func TestReplayClientReturnsCopies(t *testing.T) {
+	start := time.Unix(1000, 0)
+	records := []RecordedResponse{{Time: start, Request: "get_status", Response: statusResponse(42)}}
+	req := &pb.Request{Request: &pb.Request_GetStatus{GetStatus: &pb.GetStatusRequest{}}}
+
+	for _, speed := range []float64{0, 1} {
+		replay := NewReplayClient(records, speed)
+		replay.now = func() time.Time { return start }
+		resp, err := replay.Handle(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// As a redacted diagnostics bundle would
+		resp.GetDishGetStatus().DeviceInfo.Id = ""
+
+		if id := records[0].Response.GetDishGetStatus().GetDeviceInfo().GetId(); id != "ut01" {
+			t.Fatalf("speed %v: recorded device ID = %q after modifying a replayed response, want ut01", speed, id)
+		}
+	}
+
+	// At speed 1 the same record is replayed again
+	replay := NewReplayClient(records, 1)
+	replay.now = func() time.Time { return start }
+	first, _ := replay.Handle(req)
+	first.GetDishGetStatus().DeviceInfo.Id = ""
+	status, err := replay.GetStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.DeviceInfo.ID != "ut01" {
+		t.Errorf("Next response's device ID = %q, want ut01", status.DeviceInfo.ID)
+	}
+}
@@ -0,0 +1,363 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// reflectionRetryDelay is how long requests use the compiled-in schema after
+// fetching the device's descriptors fails
+const reflectionRetryDelay = time.Minute
+
+// deviceService is the service whose descriptors are fetched over reflection
+const deviceService = "SpaceX.API.Device.Device"
+
+// reflectionMethods are tried in order. Older servers only implement v1alpha,
+// whose messages are wire-compatible with v1.
+var reflectionMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// Kinds of SchemaDifference
+const (
+	FieldAdded       = "field_added"        // Only the device has the field; its data isn't exported
+	FieldRemoved     = "field_removed"      // Only the compiled-in protos have the field
+	FieldRenumbered  = "field_renumbered"   // Same name, different field number
+	FieldTypeChanged = "field_type_changed" // Same name, different type
+)
+
+// SchemaDifference is one field whose definition on the device differs from
+// the compiled-in protos
+type SchemaDifference struct {
+	Kind    string
+	Message string // Full message name, e.g. SpaceX.API.Device.DishGetStatusResponse
+	Field   string
+	Detail  string // e.g. "1 -> 1001" for a renumbered field
+
+	misread bool // The compiled-in protos would decode the device's data wrongly
+}
+
+// deviceSchema is a device's own Request and Response descriptors, fetched
+// over gRPC server reflection
+type deviceSchema struct {
+	request     protoreflect.MessageDescriptor
+	response    protoreflect.MessageDescriptor
+	types       *dynamicpb.Types
+	differences []SchemaDifference
+
+	// transcode is set when the compiled-in protos would misread the device's
+	// messages, or lose fields that were only renumbered. Requests and
+	// responses are then converted by field name.
+	transcode bool
+}
+
+// reflectionState fetches a device's schema on first use, retrying after
+// failures
+type reflectionState struct {
+	address string
+	logger  *slog.Logger
+
+	mu          sync.Mutex
+	schema      *deviceSchema // nil until fetched
+	retryAt     time.Time
+	unsupported bool // Device doesn't implement reflection; use the compiled-in schema for good
+}
+
+// EnableReflection fetches the device's descriptors over gRPC server reflection
+// on first use and compares them with the compiled-in protos, logging and
+// exporting the differences. If fields were renumbered, removed or changed
+// type, requests and responses are decoded with the device's descriptors and
+// converted by field name, so metrics keep flowing across firmware updates.
+// Such requests always use unary calls. It must be called before the client
+// is used.
+func (c *NativeGRPCClient) EnableReflection(logger *slog.Logger) {
+	c.reflection = &reflectionState{address: c.address, logger: logger}
+}
+
+// get returns the device's schema, fetching it if needed. It returns nil while
+// the compiled-in schema should be used.
+func (rs *reflectionState) get(ctx context.Context, conn grpc.ClientConnInterface) *deviceSchema {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.schema != nil || rs.unsupported || time.Now().Before(rs.retryAt) {
+		return rs.schema
+	}
+
+	schema, err := fetchSchema(ctx, conn)
+	if status.Code(err) == codes.Unimplemented {
+		rs.logger.Warn("Device doesn't support gRPC reflection, using the compiled-in protos", "target", rs.address)
+		rs.unsupported = true
+		return nil
+	}
+	if err != nil {
+		rs.logger.Debug("Failed to fetch device schema", "target", rs.address, "error", err, "retry_in", reflectionRetryDelay)
+		rs.retryAt = time.Now().Add(reflectionRetryDelay)
+		return nil
+	}
+
+	rs.schema = schema
+	rs.report()
+	return schema
+}
+
+// report logs the schema differences and exports their counts
+func (rs *reflectionState) report() {
+	counts := make(map[string]int)
+	for _, d := range rs.schema.differences {
+		counts[d.Kind]++
+		level := slog.LevelInfo
+		if d.Kind == FieldAdded {
+			level = slog.LevelDebug // New firmware adds fields all the time
+		}
+		rs.logger.Log(context.Background(), level, "Device schema differs",
+			"target", rs.address, "kind", d.Kind, "message", d.Message, "field", d.Field, "detail", d.Detail)
+	}
+	for _, kind := range []string{FieldAdded, FieldRemoved, FieldRenumbered, FieldTypeChanged} {
+		schemaDifferences.WithLabelValues(rs.address, kind).Set(float64(counts[kind]))
+	}
+
+	if len(rs.schema.differences) == 0 {
+		rs.logger.Info("Device schema matches the compiled-in protos", "target", rs.address)
+		return
+	}
+	rs.logger.Warn("Device schema differs from the compiled-in protos",
+		"target", rs.address,
+		"added", counts[FieldAdded],
+		"removed", counts[FieldRemoved],
+		"renumbered", counts[FieldRenumbered],
+		"type_changed", counts[FieldTypeChanged],
+		"transcoding", rs.schema.transcode)
+}
+
+// fetchSchema fetches the device's descriptors and compares them with the
+// compiled-in protos
+func fetchSchema(ctx context.Context, conn grpc.ClientConnInterface) (*deviceSchema, error) {
+	var files *protoregistry.Files
+	var err error
+	for _, method := range reflectionMethods {
+		files, err = fetchFiles(ctx, conn, method, deviceService)
+		if status.Code(err) != codes.Unimplemented {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newDeviceSchema(files)
+}
+
+// newDeviceSchema looks up the device's Request and Response in files
+func newDeviceSchema(files *protoregistry.Files) (*deviceSchema, error) {
+	localRequest := (*pb.Request)(nil).ProtoReflect().Descriptor()
+	localResponse := (*pb.Response)(nil).ProtoReflect().Descriptor()
+	request, err := findMessage(files, localRequest.FullName())
+	if err != nil {
+		return nil, err
+	}
+	response, err := findMessage(files, localResponse.FullName())
+	if err != nil {
+		return nil, err
+	}
+
+	schema := &deviceSchema{
+		request:  request,
+		response: response,
+		types:    dynamicpb.NewTypes(files),
+	}
+	visited := make(map[protoreflect.FullName]bool)
+	schema.differences = compareMessages(localRequest, request, visited, nil)
+	schema.differences = compareMessages(localResponse, response, visited, schema.differences)
+	for _, d := range schema.differences {
+		if d.misread {
+			schema.transcode = true
+		}
+	}
+	return schema, nil
+}
+
+// findMessage returns the message descriptor called name in files
+func findMessage(files *protoregistry.Files, name protoreflect.FullName) (protoreflect.MessageDescriptor, error) {
+	d, err := files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("device schema has no %s: %v", name, err)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("device schema's %s is not a message", name)
+	}
+	return md, nil
+}
+
+// fetchFiles fetches the file defining symbol and its dependencies using the
+// reflection stream method
+func fetchFiles(ctx context.Context, conn grpc.ClientConnInterface, method, symbol string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, method)
+	if err != nil {
+		return nil, err
+	}
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	requested := make(map[string]bool)
+	pending := []*rpb.ServerReflectionRequest{{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	}}
+	for len(pending) > 0 {
+		if err := stream.SendMsg(pending[0]); err != nil {
+			return nil, err
+		}
+		pending = pending[1:]
+
+		resp := &rpb.ServerReflectionResponse{}
+		if err := stream.RecvMsg(resp); err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, status.Error(codes.Code(e.ErrorCode), e.ErrorMessage)
+		}
+		for _, data := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(data, fd); err != nil {
+				return nil, fmt.Errorf("failed to decode file descriptor: %v", err)
+			}
+			protos[fd.GetName()] = fd
+		}
+
+		// Servers leave out dependencies they think the client already has
+		for _, fd := range protos {
+			for _, dep := range fd.GetDependency() {
+				if protos[dep] == nil && !requested[dep] {
+					requested[dep] = true
+					pending = append(pending, &rpb.ServerReflectionRequest{
+						MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+					})
+				}
+			}
+		}
+	}
+	stream.CloseSend()
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range protos {
+		set.File = append(set.File, fd)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device descriptors: %v", err)
+	}
+	return files, nil
+}
+
+// compareMessages appends the differences between the compiled-in message
+// local and the device's remote, recursing into message fields they share.
+// Fields are matched by name, as the transcoding does. Added fields are
+// unknown fields to the compiled-in protos, which skip them.
+func compareMessages(local, remote protoreflect.MessageDescriptor, visited map[protoreflect.FullName]bool, diffs []SchemaDifference) []SchemaDifference {
+	if visited[local.FullName()] {
+		return diffs
+	}
+	visited[local.FullName()] = true
+
+	message := string(local.FullName())
+	localFields, remoteFields := local.Fields(), remote.Fields()
+	for i := 0; i < localFields.Len(); i++ {
+		lf := localFields.Get(i)
+		rf := remoteFields.ByName(lf.Name())
+		switch {
+		case rf == nil:
+			// Harmless unless the device reuses the number for another field
+			d := SchemaDifference{Kind: FieldRemoved, Message: message, Field: string(lf.Name())}
+			if reused := remoteFields.ByNumber(lf.Number()); reused != nil {
+				d.Detail = fmt.Sprintf("%d reused by %s", lf.Number(), reused.Name())
+				d.misread = true
+			}
+			diffs = append(diffs, d)
+			continue
+		case fieldType(lf) != fieldType(rf):
+			diffs = append(diffs, SchemaDifference{Kind: FieldTypeChanged, Message: message, Field: string(lf.Name()),
+				Detail: fieldType(lf) + " -> " + fieldType(rf), misread: true})
+			continue
+		case lf.Number() != rf.Number():
+			diffs = append(diffs, SchemaDifference{Kind: FieldRenumbered, Message: message, Field: string(lf.Name()),
+				Detail: fmt.Sprintf("%d -> %d", lf.Number(), rf.Number()), misread: true})
+		}
+		if lf.Message() != nil {
+			diffs = compareMessages(lf.Message(), rf.Message(), visited, diffs)
+		}
+	}
+	for i := 0; i < remoteFields.Len(); i++ {
+		rf := remoteFields.Get(i)
+		if localFields.ByName(rf.Name()) == nil {
+			diffs = append(diffs, SchemaDifference{Kind: FieldAdded, Message: message, Field: string(rf.Name()),
+				Detail: fmt.Sprintf("%d %s", rf.Number(), fieldType(rf))})
+		}
+	}
+	return diffs
+}
+
+// fieldType describes a field's type for comparison, e.g. "repeated float" or
+// "SpaceX.API.Device.DishAlerts"
+func fieldType(fd protoreflect.FieldDescriptor) string {
+	t := fd.Kind().String()
+	switch {
+	case fd.Message() != nil && fd.IsMap():
+		t = "map<" + fieldType(fd.MapKey()) + ", " + fieldType(fd.MapValue()) + ">"
+	case fd.Message() != nil:
+		t = string(fd.Message().FullName())
+	case fd.Enum() != nil:
+		t = string(fd.Enum().FullName())
+	}
+	if fd.IsList() {
+		t = "repeated " + t
+	}
+	return t
+}
+
+// handle sends req with the device's own descriptors, converting between them
+// and the compiled-in protos by field name
+func (s *deviceSchema) handle(ctx context.Context, conn grpc.ClientConnInterface, req *pb.Request) (*pb.Response, error) {
+	dynReq := dynamicpb.NewMessage(s.request)
+	if err := s.convert(req, dynReq); err != nil {
+		return nil, fmt.Errorf("failed to convert request: %v", err)
+	}
+
+	dynResp := dynamicpb.NewMessage(s.response)
+	if err := conn.Invoke(ctx, pb.Device_Handle_FullMethodName, dynReq, dynResp); err != nil {
+		return nil, err
+	}
+
+	resp := &pb.Response{}
+	if err := s.convert(dynResp, resp); err != nil {
+		return nil, fmt.Errorf("failed to convert response: %v", err)
+	}
+	return resp, nil
+}
+
+// convert copies from into to by field name. Fields and enum values to doesn't
+// have are dropped.
+func (s *deviceSchema) convert(from, to proto.Message) error {
+	data, err := protojson.MarshalOptions{Resolver: s.types}.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true, Resolver: s.types}.Unmarshal(data, to)
+}
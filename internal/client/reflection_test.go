@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	rpbalpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// driftedFiles returns the compiled-in device descriptors as a newer firmware
+// might have them: DishGetHistoryResponse.current renumbered to 40001 and a new
+// DishGetStatusResponse field
+func driftedFiles(t *testing.T) *protoregistry.Files {
+	t.Helper()
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(deviceService)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(d.ParentFile())
+
+	for _, file := range set.File {
+		for _, msg := range file.MessageType {
+			switch msg.GetName() {
+			case "DishGetHistoryResponse":
+				for _, f := range msg.Field {
+					if f.GetName() == "current" {
+						f.Number = proto.Int32(40001)
+					}
+				}
+			case "DishGetStatusResponse":
+				msg.Field = append(msg.Field, &descriptorpb.FieldDescriptorProto{
+					Name:     proto.String("new_metric"),
+					JsonName: proto.String("newMetric"),
+					Number:   proto.Int32(9999),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_FLOAT.Enum(),
+				})
+			}
+		}
+	}
+
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// newDriftedClient returns a client connected to a device speaking files' schema,
+// serving v1alpha reflection if withReflection is set
+func newDriftedClient(t *testing.T, files *protoregistry.Files, withReflection bool) *NativeGRPCClient {
+	t.Helper()
+	request, err := findMessage(files, "SpaceX.API.Device.Request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := findMessage(files, "SpaceX.API.Device.Response")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: deviceService,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Handle",
+			Handler: func(_ any, _ context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				req := dynamicpb.NewMessage(request)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if !req.Has(request.Fields().ByName("get_history")) {
+					t.Errorf("device received %v, want get_history", req)
+				}
+				resp := dynamicpb.NewMessage(response)
+				err := protojson.Unmarshal([]byte(`{"dishGetHistory": {"current": "1234", "powerIn": [50, 60]}}`), resp)
+				return resp, err
+			},
+		}},
+	}, struct{}{})
+	if withReflection {
+		rpbalpha.RegisterServerReflectionServer(server, reflection.NewServer(reflection.ServerOptions{
+			Services:           server,
+			DescriptorResolver: files,
+		}))
+	}
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	c := &NativeGRPCClient{conn: conn, client: pb.NewDeviceClient(conn), address: "bufnet"}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestReflection_Transcodes(t *testing.T) {
+	files := driftedFiles(t)
+
+	// The compiled-in protos don't see the renumbered field
+	plain := newDriftedClient(t, files, true)
+	history, err := plain.GetHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.Current != 0 {
+		t.Fatalf("Expected the compiled-in protos to miss the renumbered field, got Current=%d", history.Current)
+	}
+
+	c := newDriftedClient(t, files, true)
+	c.EnableReflection(slog.Default())
+	history, err = c.GetHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.Current != 1234 || len(history.PowerIn) != 2 || history.PowerIn[1] != 60 {
+		t.Errorf("Unexpected history %+v", history)
+	}
+
+	schema := c.reflection.schema
+	if schema == nil || !schema.transcode {
+		t.Fatalf("Expected a transcoding schema, got %+v", schema)
+	}
+	var renumbered, added bool
+	for _, d := range schema.differences {
+		switch {
+		case d.Kind == FieldRenumbered && d.Message == "SpaceX.API.Device.DishGetHistoryResponse" && d.Field == "current":
+			renumbered = d.Detail == "1 -> 40001"
+		case d.Kind == FieldAdded && d.Message == "SpaceX.API.Device.DishGetStatusResponse" && d.Field == "new_metric":
+			added = true
+		default:
+			t.Errorf("Unexpected difference %+v", d)
+		}
+	}
+	if !renumbered || !added {
+		t.Errorf("Expected current renumbered and new_metric added, got %+v", schema.differences)
+	}
+
+	if got := testutil.ToFloat64(schemaDifferences.WithLabelValues("bufnet", FieldRenumbered)); got != 1 {
+		t.Errorf("Expected 1 renumbered field exported, got %v", got)
+	}
+}
+
+func TestReflection_Unsupported(t *testing.T) {
+	c := newDriftedClient(t, driftedFiles(t), false)
+	c.EnableReflection(slog.Default())
+	if _, err := c.GetHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.reflection.unsupported || c.reflection.schema != nil {
+		t.Errorf("Expected reflection to be marked unsupported, got %+v", c.reflection)
+	}
+}
+
+func TestCompareMessages_Matching(t *testing.T) {
+	local := (*pb.Response)(nil).ProtoReflect().Descriptor()
+	if diffs := compareMessages(local, local, make(map[protoreflect.FullName]bool), nil); len(diffs) != 0 {
+		t.Errorf("Expected no differences comparing the compiled-in protos with themselves, got %d, e.g. %+v", len(diffs), diffs[0])
+	}
+}
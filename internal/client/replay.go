@@ -0,0 +1,111 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrReplayFinished is returned once a replay has run past its last recorded response
+var ErrReplayFinished = errors.New("replay finished")
+
+// ReplayClient answers requests from a recording instead of a dish, so the
+// trackers and collectors can be run against captured traffic.
+//
+// With a positive speed, the recording plays back on a clock that starts at the
+// first request and runs speed times faster than real time. Each request gets
+// the newest recorded response of its type at the current playback position.
+// With speed 0 every request gets the next recorded response of its type, as
+// fast as the caller asks, which makes replays deterministic for tests.
+type ReplayClient struct {
+	records []RecordedResponse // Sorted by time
+	speed   float64
+	now     func() time.Time
+
+	mu    sync.Mutex
+	start time.Time      // Wall clock of the first request; zero until then
+	next  map[string]int // Step mode: index of the next record to try per request type
+}
+
+// NewReplayClient plays back records at speed (1 is real time, 0 steps through
+// responses one request at a time)
+func NewReplayClient(records []RecordedResponse, speed float64) *ReplayClient {
+	records = slices.Clone(records)
+	// A recording continued after a clock change could be out of order
+	slices.SortStableFunc(records, func(a, b RecordedResponse) int {
+		return a.Time.Compare(b.Time)
+	})
+	return &ReplayClient{
+		records: records,
+		speed:   speed,
+		now:     time.Now,
+		next:    make(map[string]int),
+	}
+}
+
+// Handle returns a copy of the recorded response for req's type, so callers
+// may modify it (e.g. to redact it) without changing the recording
+func (c *ReplayClient) Handle(req *pb.Request) (*pb.Response, error) {
+	request := requestType(req)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.records) == 0 {
+		return nil, ErrReplayFinished
+	}
+	if c.speed <= 0 {
+		return c.step(request)
+	}
+
+	now := c.now()
+	if c.start.IsZero() {
+		c.start = now
+	}
+	elapsed := time.Duration(float64(now.Sub(c.start)) * c.speed)
+	position := c.records[0].Time.Add(elapsed)
+	if position.After(c.records[len(c.records)-1].Time) {
+		return nil, ErrReplayFinished
+	}
+
+	// Newest record at or before position, then back to the newest of this type
+	i, _ := slices.BinarySearchFunc(c.records, position, func(r RecordedResponse, t time.Time) int {
+		if r.Time.After(t) {
+			return 1
+		}
+		return -1
+	})
+	for i--; i >= 0; i-- {
+		if c.records[i].Request == request {
+			return proto.Clone(c.records[i].Response).(*pb.Response), nil
+		}
+	}
+	return nil, fmt.Errorf("no %s response recorded yet", request)
+}
+
+// step returns the next record of type request
+func (c *ReplayClient) step(request string) (*pb.Response, error) {
+	for i := c.next[request]; i < len(c.records); i++ {
+		if c.records[i].Request == request {
+			c.next[request] = i + 1
+			return proto.Clone(c.records[i].Response).(*pb.Response), nil
+		}
+	}
+	c.next[request] = len(c.records)
+	return nil, ErrReplayFinished
+}
+
+// GetStatus returns the recorded get_status response
+func (c *ReplayClient) GetStatus() (*StatusResponse, error) {
+	return getStatus(c)
+}
+
+// GetHistory returns the recorded get_history response
+func (c *ReplayClient) GetHistory() (*HistoryResponse, error) {
+	return getHistory(c)
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"fmt"
+	"slices"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// requestOneof returns the Request.request oneof, whose field names are the
+// request types (get_status, get_history, ...)
+func requestOneof() protoreflect.OneofDescriptor {
+	return (*pb.Request)(nil).ProtoReflect().Descriptor().Oneofs().ByName("request")
+}
+
+// RequestNames returns every request type the Device service accepts, sorted
+func RequestNames() []string {
+	fields := requestOneof().Fields()
+	names := make([]string, fields.Len())
+	for i := range names {
+		names[i] = string(fields.Get(i).Name())
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NewRequest returns a request of the named type (e.g. "get_status") with an
+// empty request message, which is all the read-only requests need
+func NewRequest(name string) (*pb.Request, error) {
+	fd := requestOneof().Fields().ByName(protoreflect.Name(name))
+	if fd == nil || fd.Message() == nil {
+		return nil, fmt.Errorf("unknown request %q", name)
+	}
+	req := &pb.Request{}
+	m := req.ProtoReflect()
+	m.Set(fd, protoreflect.ValueOfMessage(m.NewField(fd).Message()))
+	return req, nil
+}
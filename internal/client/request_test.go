@@ -0,0 +1,46 @@
+package client
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNewRequest(t *testing.T) {
+	req, err := NewRequest("get_status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetGetStatus() == nil {
+		t.Errorf("get_status request = %v, want GetStatus set", req)
+	}
+	if got := requestType(req); got != "get_status" {
+		t.Errorf("requestType = %q, want get_status", got)
+	}
+
+	req, err = NewRequest("dish_get_obstruction_map")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.GetDishGetObstructionMap() == nil {
+		t.Errorf("dish_get_obstruction_map request = %v", req)
+	}
+
+	if _, err := NewRequest("get_nothing"); err == nil {
+		t.Error("expected an error for an unknown request")
+	}
+	if _, err := NewRequest("id"); err == nil {
+		t.Error("expected an error for a Request field outside the oneof")
+	}
+}
+
+func TestRequestNames(t *testing.T) {
+	names := RequestNames()
+	for _, want := range []string{"get_status", "get_history", "dish_get_obstruction_map"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("RequestNames() is missing %q", want)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Error("RequestNames() is not sorted")
+	}
+}
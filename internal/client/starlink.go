@@ -1,11 +1,36 @@
 package client
 
+import pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+
 // Client interface for Starlink dish communication
 type Client interface {
 	GetStatus() (*StatusResponse, error)
 	GetHistory() (*HistoryResponse, error)
 }
 
+// RawClient interface for sending arbitrary requests to a Starlink device
+type RawClient interface {
+	Handle(req *pb.Request) (*pb.Response, error)
+}
+
+// RouterClient interface for Starlink router communication
+type RouterClient interface {
+	GetRadioStats() (*RadioStatsResponse, error)
+	GetWifiHistory() (*WifiHistoryResponse, error)
+}
+
+// SelfTestClient interface for running and inspecting dish hardware self-tests
+type SelfTestClient interface {
+	RunSelfTest() (*SelfTestResponse, error)
+	GetDiagnostics() (*DiagnosticsResponse, error)
+}
+
+// MeshClient interface for Starlink mesh status on a router
+type MeshClient interface {
+	GetMeshStatus() (*MeshStatusResponse, error)
+	GetBackhaulStats() (*BackhaulStatsResponse, error)
+}
+
 // DeviceInfo contains device information
 type DeviceInfo struct {
 	ID              string `json:"id"`
@@ -46,6 +71,9 @@ type StatusResponse struct {
 	GPSStats              GPSStats         `json:"gpsStats"`
 	EthSpeedMbps          int              `json:"ethSpeedMbps"`
 	IsSnrAboveNoiseFloor  bool             `json:"isSnrAboveNoiseFloor"`
+	Alerts                []string         `json:"alerts"` // Active alerts by proto field name, e.g. "thermal_throttle"
+
+	Raw *pb.DishGetStatusResponse `json:"-"` // The full response, for fields not mapped above
 }
 
 // HistoryResponse contains historical data from the dish
@@ -57,3 +85,106 @@ type HistoryResponse struct {
 	PopPingDropRate       []float64 `json:"popPingDropRate"`
 	PowerIn               []float64 `json:"powerIn"`
 }
+
+// RadioRxStats contains receive counters for a single radio
+type RadioRxStats struct {
+	Bytes       uint64 `json:"bytes"`
+	Packets     uint64 `json:"packets"`
+	FrameErrors uint64 `json:"frameErrors"`
+}
+
+// RadioTxStats contains transmit counters for a single radio
+type RadioTxStats struct {
+	Bytes   uint64 `json:"bytes"`
+	Packets uint64 `json:"packets"`
+}
+
+// RadioThermalStatus contains thermal throttling state for a single radio
+type RadioThermalStatus struct {
+	Level          uint32  `json:"level"`
+	TempC          float64 `json:"tempC"`
+	PowerReduction uint32  `json:"powerReduction"`
+	DutyCycle      uint32  `json:"dutyCycle"`
+}
+
+// RadioAntennaStatus contains per-antenna RSSI for a single radio
+type RadioAntennaStatus struct {
+	Rssi []float64 `json:"rssi"`
+}
+
+// RadioStats contains statistics for one router radio band
+type RadioStats struct {
+	Band          string             `json:"band"`
+	RxStats       RadioRxStats       `json:"rxStats"`
+	TxStats       RadioTxStats       `json:"txStats"`
+	ThermalStatus RadioThermalStatus `json:"thermalStatus"`
+	AntennaStatus RadioAntennaStatus `json:"antennaStatus"`
+}
+
+// RadioStatsResponse contains radio statistics from the router
+type RadioStatsResponse struct {
+	RadioStats []RadioStats `json:"radioStats"`
+}
+
+// WifiHistoryResponse contains historical data from the router.
+// PingDropRate and PingLatencyMs are 1-second ring buffers indexed by Current;
+// DnsResolverDropRate holds 15-second ring buffers indexed by CurrentIndex15s.
+type WifiHistoryResponse struct {
+	Current             uint64               `json:"current"`
+	PingDropRate        []float64            `json:"pingDropRate"`
+	PingLatencyMs       []float64            `json:"pingLatencyMs"`
+	CurrentIndex15s     uint64               `json:"currentIndex15s"`
+	DnsResolverDropRate map[string][]float64 `json:"dnsResolverDropRate"`
+}
+
+// MeshNode contains the state of one mesh router as seen by the controller
+type MeshNode struct {
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	MacAddress         string  `json:"macAddress"`
+	Role               string  `json:"role"`
+	HardwareVersion    string  `json:"hardwareVersion"`
+	SoftwareVersion    string  `json:"softwareVersion"`
+	HopsFromController uint32  `json:"hopsFromController"`
+	EstTxRateMbps      float64 `json:"estTxRateMbps"`
+	EstRxRateMbps      float64 `json:"estRxRateMbps"`
+	SignalStrength     float64 `json:"signalStrength"`
+}
+
+// MeshStatusResponse contains the mesh nodes known to the router
+type MeshStatusResponse struct {
+	Nodes []MeshNode `json:"nodes"`
+}
+
+// BackhaulCandidate contains one upstream access point seen by a backhaul site survey
+type BackhaulCandidate struct {
+	Bssid         string  `json:"bssid"`
+	Ssid          string  `json:"ssid"`
+	Channel       uint32  `json:"channel"`
+	Rssi          float64 `json:"rssi"`
+	EstRxRateMbps float64 `json:"estRxRateMbps"`
+}
+
+// BackhaulStatsResponse contains backhaul link statistics from a mesh router
+type BackhaulStatsResponse struct {
+	Success    bool                `json:"success"`
+	Bssid      string              `json:"bssid"`
+	Iface      string              `json:"iface"`
+	Preference uint32              `json:"preference"`
+	Candidates []BackhaulCandidate `json:"candidates"`
+}
+
+// SelfTestResponse contains the result of an on-dish self-test
+type SelfTestResponse struct {
+	Passed bool   `json:"passed"`
+	Report string `json:"report"`
+}
+
+// DiagnosticsResponse contains diagnostics data from the dish
+type DiagnosticsResponse struct {
+	ID                    string   `json:"id"`
+	HardwareVersion       string   `json:"hardwareVersion"`
+	SoftwareVersion       string   `json:"softwareVersion"`
+	HardwareSelfTest      string   `json:"hardwareSelfTest"`
+	HardwareSelfTestCodes []string `json:"hardwareSelfTestCodes"`
+}
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// streamRetryDelay is how long requests use unary calls after the stream breaks
+// before it is reopened
+const streamRetryDelay = 5 * time.Second
+
+// streamFirstResponseTimeout is how long a new stream may go without answering
+// before the device is assumed not to support streaming. It leaves the rest of
+// the request timeout for the unary retry.
+const streamFirstResponseTimeout = 5 * time.Second
+
+// errStreamUnavailable means a request never reached the device over the
+// stream and should be retried as a unary call
+var errStreamUnavailable = errors.New("stream unavailable")
+
+// streamResult is the outcome of a request sent over the stream
+type streamResult struct {
+	resp *pb.Response
+	err  error // Set if the stream broke before the response arrived
+}
+
+// streamTransport multiplexes requests over one long-lived Device.Stream,
+// matching responses to requests by Request.Id. The stream is opened on first
+// use and reopened after it breaks. If the device rejects streaming, never
+// answers on a new stream, or doesn't echo request IDs, the transport disables
+// itself for good.
+//
+// Only requests that never reached the device fall back to unary calls; one the
+// device may have run is failed with Unavailable rather than sent twice.
+type streamTransport struct {
+	client  pb.DeviceClient
+	address string
+	ctx     context.Context // Parent of every stream; cancelled by close
+	cancel  context.CancelFunc
+
+	firstResponseTimeout time.Duration
+
+	mu           sync.Mutex
+	stream       pb.Device_StreamClient // nil while disconnected
+	streamCancel context.CancelFunc
+	answered     bool                         // stream has delivered a response
+	pending      map[uint64]chan streamResult // Requests in flight on stream
+	nextID       uint64
+	retryAt      time.Time // Don't reopen before this
+	disabled     bool      // Device doesn't support streaming
+
+	sendMu sync.Mutex // Serializes Send, which isn't safe for concurrent use
+}
+
+// newStreamTransport creates a stream transport; nothing is opened until first use
+func newStreamTransport(c pb.DeviceClient, address string) *streamTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &streamTransport{
+		client:  c,
+		address: address,
+		ctx:     ctx,
+		cancel:  cancel,
+		pending: make(map[uint64]chan streamResult),
+
+		firstResponseTimeout: streamFirstResponseTimeout,
+	}
+}
+
+// handle sends req over the stream and waits for its response. It returns
+// errStreamUnavailable if the request should be retried as a unary call.
+func (st *streamTransport) handle(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	stream, id, ch, answered, err := st.register()
+	if err != nil {
+		return nil, errStreamUnavailable
+	}
+	defer st.unregister(id)
+
+	// Callers may share request values, so tag a copy with the stream ID
+	req = proto.Clone(req).(*pb.Request)
+	req.Id = id
+
+	st.sendMu.Lock()
+	err = stream.Send(&pb.ToDevice{Message: &pb.ToDevice_Request{Request: req}})
+	st.sendMu.Unlock()
+	if err != nil {
+		// The request wasn't sent, but others on the stream may have been
+		st.fail(stream, err, false, false)
+		return nil, errStreamUnavailable
+	}
+
+	// A device that accepts the stream but never answers on it doesn't support
+	// streaming; give up on it in time for the unary retry
+	var firstResponse <-chan time.Time
+	if !answered {
+		timer := time.NewTimer(st.firstResponseTimeout)
+		defer timer.Stop()
+		firstResponse = timer.C
+	}
+
+	for {
+		select {
+		case result := <-ch:
+			if result.err != nil {
+				return nil, result.err
+			}
+			return result.resp, responseError(result.resp)
+		case <-firstResponse:
+			firstResponse = nil
+			st.mu.Lock()
+			silent := st.stream == stream && !st.answered
+			st.mu.Unlock()
+			if silent {
+				st.fail(stream, errors.New("device accepted the stream but never answered"), true, true)
+			}
+			// Keep waiting: fail delivered the result, or the stream has answered
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// register allocates a request ID on the current stream, opening one if needed.
+// answered reports whether the stream has delivered a response yet.
+func (st *streamTransport) register() (stream pb.Device_StreamClient, id uint64, ch chan streamResult, answered bool, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.disabled || time.Now().Before(st.retryAt) {
+		return nil, 0, nil, false, errStreamUnavailable
+	}
+
+	if st.stream == nil {
+		ctx, cancel := context.WithCancel(st.ctx)
+		stream, err := st.client.Stream(ctx)
+		if err != nil {
+			cancel()
+			st.retryAt = time.Now().Add(streamRetryDelay)
+			return nil, 0, nil, false, err
+		}
+		st.stream = stream
+		st.streamCancel = cancel
+		st.answered = false
+		streamConnects.WithLabelValues(st.address).Inc()
+		go st.recvLoop(stream)
+	}
+
+	// IDs start at 1; the device leaves 0 in responses it doesn't correlate
+	st.nextID++
+	ch = make(chan streamResult, 1)
+	st.pending[st.nextID] = ch
+	return st.stream, st.nextID, ch, st.answered, nil
+}
+
+// unregister forgets a request that has completed or timed out
+func (st *streamTransport) unregister(id uint64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.pending, id)
+}
+
+// recvLoop delivers responses from stream to waiting requests until it breaks
+func (st *streamTransport) recvLoop(stream pb.Device_StreamClient) {
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			// Devices without streaming support reject it on the first receive,
+			// without running the requests sent on it
+			unsupported := status.Code(err) == codes.Unimplemented
+			st.fail(stream, err, unsupported, unsupported)
+			return
+		}
+
+		resp := msg.GetResponse()
+		if resp == nil {
+			// Events and health checks aren't requested by the exporter
+			continue
+		}
+		if resp.Id == 0 {
+			st.fail(stream, errors.New("device does not echo request IDs"), true, false)
+			return
+		}
+
+		st.mu.Lock()
+		if st.stream == stream {
+			st.answered = true
+		}
+		if ch, ok := st.pending[resp.Id]; ok && st.stream == stream {
+			select {
+			case ch <- streamResult{resp: resp}:
+			default:
+				// Duplicate response for the same ID
+			}
+		}
+		st.mu.Unlock()
+	}
+}
+
+// fail tears down stream and fails its pending requests. If retry, they never
+// reached the device and fall back to unary calls; otherwise the device may have
+// run them, so they fail with Unavailable. If permanent, the stream is never
+// reopened.
+func (st *streamTransport) fail(stream pb.Device_StreamClient, err error, permanent, retry bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.stream != stream {
+		// Already replaced
+		return
+	}
+
+	st.streamCancel()
+	st.stream = nil
+	st.streamCancel = nil
+	result := streamResult{err: errStreamUnavailable}
+	if !retry {
+		result.err = status.Errorf(codes.Unavailable, "stream broke before the response arrived: %v", err)
+	}
+	for id, ch := range st.pending {
+		select {
+		case ch <- result:
+		default:
+			// Already answered
+		}
+		delete(st.pending, id)
+	}
+
+	if permanent {
+		st.disabled = true
+	} else if st.ctx.Err() == nil {
+		st.retryAt = time.Now().Add(streamRetryDelay)
+	}
+}
+
+// close closes the stream and prevents it from being reopened
+func (st *streamTransport) close() {
+	st.cancel()
+}
+
+// responseError converts a non-OK status embedded in a stream response into a
+// gRPC error, matching what the unary Handle call returns
+func responseError(resp *pb.Response) error {
+	if s := resp.GetStatus(); s != nil && codes.Code(s.Code) != codes.OK {
+		return status.Error(codes.Code(s.Code), s.Message)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	statuspb "github.com/R167/starlink_exporter/proto/spacex_api/common/status"
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeDeviceServer struct {
+	pb.UnimplementedDeviceServer
+	streaming bool // Serve Device.Stream (otherwise reject it as unimplemented)
+	silent    bool // Accept requests on the stream but never answer
+	breaks    bool // Break the stream after receiving a request
+	unary     atomic.Int32
+	streamed  atomic.Int32
+}
+
+func (s *fakeDeviceServer) Handle(ctx context.Context, req *pb.Request) (*pb.Response, error) {
+	s.unary.Add(1)
+	return &pb.Response{Id: req.Id, Response: &pb.Response_DishGetStatus{DishGetStatus: &pb.DishGetStatusResponse{}}}, nil
+}
+
+func (s *fakeDeviceServer) Stream(srv grpc.BidiStreamingServer[pb.ToDevice, pb.FromDevice]) error {
+	if !s.streaming {
+		return status.Error(codes.Unimplemented, "streaming not supported")
+	}
+	for {
+		msg, err := srv.Recv()
+		if err != nil {
+			return nil
+		}
+		s.streamed.Add(1)
+		if s.silent {
+			continue
+		}
+		if s.breaks {
+			return status.Error(codes.Internal, "device restarted")
+		}
+		resp := &pb.Response{Id: msg.GetRequest().Id, Response: &pb.Response_DishGetStatus{DishGetStatus: &pb.DishGetStatusResponse{}}}
+		if err := srv.Send(&pb.FromDevice{Message: &pb.FromDevice_Response{Response: resp}}); err != nil {
+			return err
+		}
+	}
+}
+
+// newTestClient returns a streaming client connected to srv over an in-memory listener
+func newTestClient(t *testing.T, srv pb.DeviceServer) *NativeGRPCClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pb.RegisterDeviceServer(server, srv)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	c := &NativeGRPCClient{conn: conn, client: pb.NewDeviceClient(conn), address: "bufnet"}
+	c.EnableStream()
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// handleConcurrently sends n get_status requests at once and fails on any error
+func handleConcurrently(t *testing.T, c *NativeGRPCClient, n int) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for range n {
+		wg.Go(func() {
+			resp, err := c.Handle(&pb.Request{Request: &pb.Request_GetStatus{GetStatus: &pb.GetStatusRequest{}}})
+			if err != nil {
+				t.Errorf("Handle failed: %v", err)
+				return
+			}
+			if resp.GetDishGetStatus() == nil {
+				t.Error("Expected dish status in response")
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestStreamTransport_Multiplexes(t *testing.T) {
+	srv := &fakeDeviceServer{streaming: true}
+	c := newTestClient(t, srv)
+
+	handleConcurrently(t, c, 10)
+
+	if streamed := srv.streamed.Load(); streamed != 10 {
+		t.Errorf("Expected 10 requests over the stream, got %d", streamed)
+	}
+	if unary := srv.unary.Load(); unary != 0 {
+		t.Errorf("Expected no unary calls, got %d", unary)
+	}
+}
+
+func TestStreamTransport_FallsBackWhenUnsupported(t *testing.T) {
+	srv := &fakeDeviceServer{streaming: false}
+	c := newTestClient(t, srv)
+
+	handleConcurrently(t, c, 3)
+	handleConcurrently(t, c, 3)
+
+	if unary := srv.unary.Load(); unary != 6 {
+		t.Errorf("Expected every request to fall back to a unary call, got %d", unary)
+	}
+	c.stream.mu.Lock()
+	disabled := c.stream.disabled
+	c.stream.mu.Unlock()
+	if !disabled {
+		t.Error("Expected stream to be disabled after Unimplemented")
+	}
+}
+
+func TestStreamTransport_NoRetryAfterSend(t *testing.T) {
+	srv := &fakeDeviceServer{streaming: true, breaks: true}
+	c := newTestClient(t, srv)
+
+	_, err := c.Handle(&pb.Request{Request: &pb.Request_GetStatus{GetStatus: &pb.GetStatusRequest{}}})
+	if err == nil || !strings.Contains(err.Error(), "Unavailable") {
+		t.Errorf("Expected Unavailable when the stream breaks after sending, got %v", err)
+	}
+	if unary := srv.unary.Load(); unary != 0 {
+		t.Errorf("Expected the sent request not to be retried as a unary call, got %d", unary)
+	}
+}
+
+func TestStreamTransport_FallsBackWhenSilent(t *testing.T) {
+	srv := &fakeDeviceServer{streaming: true, silent: true}
+	c := newTestClient(t, srv)
+	c.stream.firstResponseTimeout = 100 * time.Millisecond
+
+	handleConcurrently(t, c, 3)
+	handleConcurrently(t, c, 3)
+
+	if unary := srv.unary.Load(); unary != 6 {
+		t.Errorf("Expected every request to fall back to a unary call, got %d", unary)
+	}
+	c.stream.mu.Lock()
+	disabled := c.stream.disabled
+	c.stream.mu.Unlock()
+	if !disabled {
+		t.Error("Expected stream to be disabled after it never answered")
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	resp := &pb.Response{Status: &statuspb.Status{Code: int32(codes.PermissionDenied), Message: "denied"}}
+	if code := status.Code(responseError(resp)); code != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied, got %v", code)
+	}
+	if err := responseError(&pb.Response{Status: &statuspb.Status{}}); err != nil {
+		t.Errorf("Expected no error for OK status, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxUnknownDump is the most bytes of an unknown field included in its log line
+const maxUnknownDump = 256
+
+// unknownField is one field a message carried that the compiled-in protos
+// don't know, usually added by newer firmware
+type unknownField struct {
+	message protoreflect.FullName
+	number  protowire.Number
+	raw     []byte // Tag and value as received
+}
+
+// unknownFieldKey identifies an unknown field across responses
+type unknownFieldKey struct {
+	message protoreflect.FullName
+	number  protowire.Number
+}
+
+// unknownFieldLog logs the first occurrence of each unknown field
+type unknownFieldLog struct {
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	seen map[unknownFieldKey]bool
+}
+
+// LogUnknownFields logs the first occurrence of each field the compiled-in
+// protos don't know, with a hex dump of its raw bytes. Unknown fields are
+// counted whether or not this is enabled. It must be called before the client
+// is used.
+func (c *NativeGRPCClient) LogUnknownFields(logger *slog.Logger) {
+	c.unknownLog = &unknownFieldLog{logger: logger, seen: make(map[unknownFieldKey]bool)}
+}
+
+// checkUnknownFields counts the message types and field numbers in resp that
+// the compiled-in protos don't know, once per response each
+func (c *NativeGRPCClient) checkUnknownFields(resp *pb.Response) {
+	counted := make(map[unknownFieldKey]bool)
+	for _, f := range findUnknownFields(resp.ProtoReflect(), nil) {
+		key := unknownFieldKey{f.message, f.number}
+		if counted[key] {
+			continue
+		}
+		counted[key] = true
+		unknownFields.WithLabelValues(string(f.message), strconv.Itoa(int(f.number))).Inc()
+		if c.unknownLog != nil {
+			c.unknownLog.log(c.address, f)
+		}
+	}
+}
+
+// log logs f unless its field was logged before
+func (l *unknownFieldLog) log(target string, f unknownField) {
+	key := unknownFieldKey{f.message, f.number}
+	l.mu.Lock()
+	seen := l.seen[key]
+	l.seen[key] = true
+	l.mu.Unlock()
+	if seen {
+		return
+	}
+
+	dump := f.raw[:min(len(f.raw), maxUnknownDump)]
+	l.logger.Info("Unknown field in device response, regenerate the protos to export it",
+		"target", target,
+		"message", f.message,
+		"field", f.number,
+		"bytes", len(f.raw),
+		"hex", hex.EncodeToString(dump))
+}
+
+// findUnknownFields appends the unknown fields of m and every message it contains
+func findUnknownFields(m protoreflect.Message, found []unknownField) []unknownField {
+	raw := m.GetUnknown()
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			break
+		}
+		v := protowire.ConsumeFieldValue(num, typ, raw[n:])
+		if v < 0 {
+			break
+		}
+		found = append(found, unknownField{message: m.Descriptor().FullName(), number: num, raw: raw[:n+v]})
+		raw = raw[n+v:]
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					found = findUnknownFields(mv.Message(), found)
+					return true
+				})
+			}
+		case fd.Message() == nil:
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				found = findUnknownFields(list.Get(i).Message(), found)
+			}
+		default:
+			found = findUnknownFields(v.Message(), found)
+		}
+		return true
+	})
+	return found
+}
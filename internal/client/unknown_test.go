@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// withUnknown sets an unknown varint field on m
+func withUnknown(m protoreflect.ProtoMessage, num protowire.Number, v uint64) {
+	raw := protowire.AppendTag(nil, num, protowire.VarintType)
+	raw = protowire.AppendVarint(raw, v)
+	r := m.ProtoReflect()
+	r.SetUnknown(append(r.GetUnknown(), raw...))
+}
+
+func TestFindUnknownFields(t *testing.T) {
+	alerts := &pb.DishAlerts{}
+	withUnknown(alerts, 9001, 1)
+	status := &pb.DishGetStatusResponse{Alerts: alerts}
+	withUnknown(status, 9002, 300)
+	resp := &pb.Response{Response: &pb.Response_DishGetStatus{DishGetStatus: status}}
+
+	found := findUnknownFields(resp.ProtoReflect(), nil)
+	if len(found) != 2 {
+		t.Fatalf("Expected 2 unknown fields, got %+v", found)
+	}
+	if found[0].message != "SpaceX.API.Device.DishGetStatusResponse" || found[0].number != 9002 {
+		t.Errorf("Unexpected first field %+v", found[0])
+	}
+	if found[1].message != "SpaceX.API.Device.DishAlerts" || found[1].number != 9001 {
+		t.Errorf("Unexpected second field %+v", found[1])
+	}
+
+	if found := findUnknownFields((&pb.Response{}).ProtoReflect(), nil); len(found) != 0 {
+		t.Errorf("Expected no unknown fields, got %+v", found)
+	}
+}
+
+func TestCheckUnknownFields(t *testing.T) {
+	var logs bytes.Buffer
+	c := &NativeGRPCClient{address: "test"}
+	c.LogUnknownFields(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	history := &pb.DishGetHistoryResponse{}
+	withUnknown(history, 9100, 7)
+	withUnknown(history, 9100, 8) // Counted once per response
+	resp := &pb.Response{Response: &pb.Response_DishGetHistory{DishGetHistory: history}}
+
+	counter := unknownFields.WithLabelValues("SpaceX.API.Device.DishGetHistoryResponse", "9100")
+	before := testutil.ToFloat64(counter)
+	c.checkUnknownFields(resp)
+	c.checkUnknownFields(resp)
+	if got := testutil.ToFloat64(counter) - before; got != 2 {
+		t.Errorf("Expected the field counted once per response, got %v", got)
+	}
+
+	// Only the first occurrence is logged; tag 9100 varint is e0 b8 04, then 07
+	if n := strings.Count(logs.String(), "Unknown field"); n != 1 {
+		t.Errorf("Expected one log line, got %d: %s", n, logs.String())
+	}
+	if !strings.Contains(logs.String(), "hex=e0b80407") {
+		t.Errorf("Expected a hex dump of the field, got %s", logs.String())
+	}
+}
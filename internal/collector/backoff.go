@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// maxBackoff caps the delay between polls of an unreachable device
+const maxBackoff = 60 * time.Second
+
+// backoff spaces out polls after consecutive failures. The delay doubles from
+// base on each failure up to maxBackoff, with jitter so several exporters
+// watching one device don't retry in lockstep.
+type backoff struct {
+	base     time.Duration
+	failures int       // Consecutive failures
+	next     time.Time // No attempt before this
+}
+
+// ready reports whether an attempt is allowed at now
+func (b *backoff) ready(now time.Time) bool {
+	return !now.Before(b.next)
+}
+
+// fail records a failed attempt at now and returns the delay until the next one
+func (b *backoff) fail(now time.Time) time.Duration {
+	b.failures++
+
+	delay := maxBackoff
+	if shift := b.failures - 1; shift < 32 {
+		delay = min(b.base<<shift, maxBackoff)
+	}
+	// Equal jitter: somewhere between half and all of the delay
+	delay = delay/2 + rand.N(delay/2+1)
+
+	b.next = now.Add(delay)
+	return delay
+}
+
+// succeed records a successful attempt and returns how many failures preceded it
+func (b *backoff) succeed() int {
+	failures := b.failures
+	b.failures = 0
+	b.next = time.Time{}
+	return failures
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := &backoff{base: time.Second}
+	now := time.Now()
+
+	if !b.ready(now) {
+		t.Fatal("Expected first attempt to be allowed")
+	}
+
+	// Delays double from base with up to 50% jitter, capped at maxBackoff
+	for i, want := range []time.Duration{1, 2, 4, 8, 16, 32, 60, 60} {
+		want *= time.Second
+		delay := b.fail(now)
+		if delay < want/2 || delay > want {
+			t.Errorf("Failure %d: expected delay in [%v, %v], got %v", i+1, want/2, want, delay)
+		}
+		if b.ready(now.Add(delay - time.Millisecond)) {
+			t.Errorf("Failure %d: expected attempt to be blocked before the delay", i+1)
+		}
+		if !b.ready(now.Add(delay)) {
+			t.Errorf("Failure %d: expected attempt to be allowed after the delay", i+1)
+		}
+	}
+
+	if failures := b.succeed(); failures != 8 {
+		t.Errorf("Expected 8 failures before success, got %d", failures)
+	}
+	if !b.ready(now) {
+		t.Error("Expected attempts to be allowed after success")
+	}
+}
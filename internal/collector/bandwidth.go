@@ -2,49 +2,154 @@ package collector
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// maxAnchorDrift is how far the newest sample's counter-derived time may stray
+// from the clock before sample times are re-anchored
+const maxAnchorDrift = 2 * time.Second
+
 // BandwidthTracker tracks cumulative metrics from history with a background ticker
 // Despite the name, it tracks bandwidth, power, and ping metrics
 type BandwidthTracker struct {
+	historyCursor          // Position in the dish history ring buffer
 	mu                     sync.RWMutex
 	client                 client.Client
 	logger                 *slog.Logger
-	lastCurrent            uint64  // Last seen history timestamp
-	downloadBytesTotal     float64 // Cumulative download bytes
-	uploadBytesTotal       float64 // Cumulative upload bytes
-	energyJoulesTotal      float64 // Cumulative energy consumed (joules = watt-seconds)
-	pingLatencySecondsSum  float64 // Sum of ping latencies in seconds (summary metric)
-	pingLatencySampleCount float64 // Count of ping samples (summary metric)
-	pingDropCount          float64 // Count of ping drops
-	lastError              error   // Last error encountered
-	initialized            bool
+	downloadBytesTotal     float64   // Cumulative download bytes
+	uploadBytesTotal       float64   // Cumulative upload bytes
+	energyJoulesTotal      float64   // Cumulative energy consumed (joules = watt-seconds)
+	pingLatencySecondsSum  float64   // Sum of ping latencies in seconds (summary metric)
+	pingLatencySampleCount float64   // Count of ping samples (summary metric)
+	pingDropCount          float64   // Count of ping drops
+	lastError              error     // Last error encountered
+	lastSuccess            time.Time // Time of the last successful history fetch
+	anchorTime             time.Time // Time of the sample before anchorCurrent
+	anchorCurrent          uint64    // History counter when the cursor (re)started
+	tickLag                float64   // Seconds between the last tick and its update starting
+	lastTick               time.Time // When the Start loop last ticked, backoff included
+	interval               time.Duration
+	backoff                backoff         // Only touched by the Start goroutine
+	handlers               []SampleHandler // Set before Start
 	stopCh                 chan struct{}
 	stoppedCh              chan struct{}
 	stopOnce               sync.Once
+
+	// Self-instrumentation
+	tickLagDesc          *prometheus.Desc
+	lastSuccessDesc      *prometheus.Desc
+	samplesProcessedDesc *prometheus.Desc
+	gapsDesc             *prometheus.Desc
+	resetsDesc           *prometheus.Desc
 }
 
+// HistorySample is one per-second sample from the dish history
+type HistorySample struct {
+	Time                  time.Time `json:"time"`
+	DownlinkThroughputBps float64   `json:"downlinkThroughputBps"`
+	UplinkThroughputBps   float64   `json:"uplinkThroughputBps"`
+	PopPingLatencyMs      float64   `json:"popPingLatencyMs"`
+	PopPingDropRate       float64   `json:"popPingDropRate"`
+	PowerInWatts          float64   `json:"powerInWatts"`
+}
+
+// SampleHandler receives the samples integrated by one poll, oldest first. It is
+// called from the tracker goroutine and must not block.
+type SampleHandler func([]HistorySample)
+
 // NewBandwidthTracker creates a new bandwidth tracker
 func NewBandwidthTracker(client client.Client, logger *slog.Logger) *BandwidthTracker {
 	return &BandwidthTracker{
 		client:    client,
 		logger:    logger,
+		interval:  1 * time.Second,
 		stopCh:    make(chan struct{}),
 		stoppedCh: make(chan struct{}),
+
+		tickLagDesc: prometheus.NewDesc(
+			"starlink_exporter_tracker_tick_lag_seconds",
+			"Delay between the history tracker's last tick and its update starting",
+			nil, nil,
+		),
+		lastSuccessDesc: prometheus.NewDesc(
+			"starlink_exporter_tracker_last_success_timestamp_seconds",
+			"Unix timestamp of the history tracker's last successful history fetch (0 = never)",
+			nil, nil,
+		),
+		samplesProcessedDesc: prometheus.NewDesc(
+			"starlink_exporter_tracker_samples_processed_total",
+			"Total history samples integrated by the history tracker",
+			nil, nil,
+		),
+		gapsDesc: prometheus.NewDesc(
+			"starlink_exporter_tracker_gaps_total",
+			"Total polls where more time passed than the history buffer holds, losing samples",
+			nil, nil,
+		),
+		resetsDesc: prometheus.NewDesc(
+			"starlink_exporter_tracker_resets_total",
+			"Total history counter resets detected (dish restarts)",
+			nil, nil,
+		),
+	}
+}
+
+// SetInterval changes how often history is polled (must be called before Start).
+// Samples are recorded every second, so longer intervals only reduce RPC load.
+func (bt *BandwidthTracker) SetInterval(interval time.Duration) {
+	bt.interval = interval
+}
+
+// AddSampleHandler registers h to receive every new history sample (must be
+// called before Start)
+func (bt *BandwidthTracker) AddSampleHandler(h SampleHandler) {
+	bt.handlers = append(bt.handlers, h)
+}
+
+// InheritState copies the cumulative counters from a previous tracker so they stay
+// monotonic across config reloads. The ring buffer cursor is only carried over when
+// both trackers poll the same dish; otherwise the new tracker re-initializes.
+// prev must be stopped first.
+func (bt *BandwidthTracker) InheritState(prev *BandwidthTracker, keepCursor bool) {
+	prev.mu.RLock()
+	defer prev.mu.RUnlock()
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	bt.downloadBytesTotal = prev.downloadBytesTotal
+	bt.uploadBytesTotal = prev.uploadBytesTotal
+	bt.energyJoulesTotal = prev.energyJoulesTotal
+	bt.pingLatencySecondsSum = prev.pingLatencySecondsSum
+	bt.pingLatencySampleCount = prev.pingLatencySampleCount
+	bt.pingDropCount = prev.pingDropCount
+	bt.tickLag = prev.tickLag
+	bt.lastSuccess = prev.lastSuccess
+	if keepCursor {
+		bt.historyCursor = prev.historyCursor
+		bt.anchorTime = prev.anchorTime
+		bt.anchorCurrent = prev.anchorCurrent
+	} else {
+		bt.historyCursor = historyCursor{stats: prev.stats}
 	}
 }
 
-// Start begins the background ticker that updates bandwidth counters every second
+// Start begins the background ticker that updates bandwidth counters every interval
 func (bt *BandwidthTracker) Start(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Second)
+	ticker := time.NewTicker(bt.interval)
 	defer ticker.Stop()
 	defer close(bt.stoppedCh)
 
+	bt.backoff.base = bt.interval
+	bt.mu.Lock()
+	bt.lastTick = time.Now()
+	bt.mu.Unlock()
 	bt.logger.Info("Bandwidth tracker started")
 
 	for {
@@ -55,7 +160,22 @@ func (bt *BandwidthTracker) Start(ctx context.Context) {
 		case <-bt.stopCh:
 			bt.logger.Info("Bandwidth tracker stopping")
 			return
-		case <-ticker.C:
+		case tick := <-ticker.C:
+			bt.mu.Lock()
+			bt.lastTick = time.Now()
+			bt.mu.Unlock()
+
+			if !bt.backoff.ready(tick) {
+				// Dish unreachable; wait out the backoff
+				continue
+			}
+
+			// A slow update delays the next tick; record how late this one is
+			lag := time.Since(tick).Seconds()
+			bt.mu.Lock()
+			bt.tickLag = lag
+			bt.mu.Unlock()
+
 			bt.update()
 		}
 	}
@@ -69,33 +189,52 @@ func (bt *BandwidthTracker) Stop() {
 	<-bt.stoppedCh
 }
 
-// update fetches history and updates counters (called every second by ticker)
+// update fetches history and updates counters (called on every tick outside backoff)
 func (bt *BandwidthTracker) update() {
 	history, err := bt.client.GetHistory()
 	if err != nil {
 		bt.mu.Lock()
 		bt.lastError = err
 		bt.mu.Unlock()
-		bt.logger.Warn("Failed to get history", "error", err)
+
+		// Only the first failure in a row is a warning; a powered-off dish
+		// shouldn't flood the log
+		delay := bt.backoff.fail(time.Now())
+		if bt.backoff.failures == 1 {
+			bt.logger.Warn("Failed to get history, backing off", "error", err, "retry_in", delay)
+		} else {
+			bt.logger.Debug("Failed to get history", "error", err, "failures", bt.backoff.failures, "retry_in", delay)
+		}
 		return
 	}
+	if failures := bt.backoff.succeed(); failures > 0 {
+		bt.logger.Info("History fetch recovered", "failures", failures)
+	}
 
-	bt.processHistory(history)
+	samples := bt.processHistory(history)
+	if len(samples) > 0 {
+		for _, h := range bt.handlers {
+			h(samples)
+		}
+	}
 }
 
-// processHistory processes new history data and updates counters
-func (bt *BandwidthTracker) processHistory(history *client.HistoryResponse) {
+// processHistory processes new history data and updates counters. It returns the
+// new samples, timestamped by their history counter from the second the cursor
+// started in.
+func (bt *BandwidthTracker) processHistory(history *client.HistoryResponse) []HistorySample {
 	bt.mu.Lock()
 	defer bt.mu.Unlock()
 
 	// Clear error on successful fetch
 	bt.lastError = nil
+	bt.lastSuccess = time.Now()
 
 	// Validate array lengths match
 	arrayLen := len(history.DownlinkThroughputBps)
 	if arrayLen == 0 {
 		bt.logger.Warn("Empty history arrays")
-		return
+		return nil
 	}
 
 	if len(history.UplinkThroughputBps) != arrayLen ||
@@ -108,58 +247,48 @@ func (bt *BandwidthTracker) processHistory(history *client.HistoryResponse) {
 			"power", len(history.PowerIn),
 			"ping_latency", len(history.PopPingLatencyMs),
 			"ping_drop", len(history.PopPingDropRate))
-		return
-	}
-
-	// Parse current timestamp - now using uint64 directly
-	current := history.Current
-
-	// On first run, just record the current timestamp
-	if !bt.initialized {
-		bt.lastCurrent = current
-		bt.initialized = true
-		bt.logger.Info("Bandwidth tracker initialized", "current", current)
-		return
+		return nil
 	}
 
-	// Detect counter reset (dishy restart)
-	if current < bt.lastCurrent {
-		bt.logger.Warn("Counter reset detected (dishy restart?)",
-			"previous", bt.lastCurrent,
-			"current", current)
-		bt.lastCurrent = current
-		// Don't reset counters - keep accumulating across restarts
-		return
-	}
-
-	// Calculate how many new samples we have
-	timeDelta := current - bt.lastCurrent
-
-	if timeDelta == 0 {
-		// No new data yet
-		return
+	// Sample times follow the history counter from the second the cursor starts
+	// (or restarts) in. Stamping each poll from the clock instead would give two
+	// polls the same second when they straddle the dish's second boundary. The
+	// anchor moves when the counter stops agreeing with the clock, e.g. after a
+	// reboot the counter didn't go backwards across, or as the clocks drift.
+	now := bt.lastSuccess.Truncate(time.Second)
+	newest := bt.anchorTime.Add(time.Duration(int64(history.Current)-int64(bt.anchorCurrent)) * time.Second)
+	if !bt.initialized || history.Current < bt.lastCurrent || newest.Sub(now).Abs() > maxAnchorDrift {
+		bt.anchorTime = now
+		bt.anchorCurrent = history.Current
 	}
 
-	// The history arrays are CIRCULAR BUFFERS
-	// Current timestamp tells us which index is "now": index = Current % arrayLength
-	bufferLen := uint64(arrayLen)
-
-	// Cap timeDelta to avoid processing more than the buffer size
-	if timeDelta > bufferLen {
-		bt.logger.Warn("Time delta exceeds history buffer size, possible data loss",
-			"delta", timeDelta,
-			"buffer_size", bufferLen)
-		timeDelta = bufferLen
+	// The history arrays are CIRCULAR BUFFERS; the cursor works out which
+	// indices hold samples we have not integrated yet
+	indices := bt.newSamples(history.Current, arrayLen, bt.logger)
+	if len(indices) == 0 {
+		return nil
 	}
+	timeDelta := len(indices)
 
-	// Iterate through the circular buffer from lastCurrent+1 to lastCurrent+timeDelta
 	// Integrate all metrics: bandwidth (bytes), power (joules), ping latency (ms), ping drops (count)
 	var downloadDelta, uploadDelta, energyDelta, pingLatencyDelta, pingDropDelta float64
-	var sampleIndices []int
 
-	for i := uint64(0); i < timeDelta; i++ {
-		t := bt.lastCurrent + i
-		idx := int(t % bufferLen)
+	var samples []HistorySample
+	if len(bt.handlers) > 0 {
+		samples = make([]HistorySample, len(indices))
+	}
+
+	for i, idx := range indices {
+		if samples != nil {
+			samples[i] = HistorySample{
+				Time:                  bt.sampleTime(history.Current, idx, arrayLen),
+				DownlinkThroughputBps: history.DownlinkThroughputBps[idx],
+				UplinkThroughputBps:   history.UplinkThroughputBps[idx],
+				PopPingLatencyMs:      history.PopPingLatencyMs[idx],
+				PopPingDropRate:       history.PopPingDropRate[idx],
+				PowerInWatts:          history.PowerIn[idx],
+			}
+		}
 
 		// Bandwidth: convert bits/sec to bytes (each sample = 1 second)
 		downloadDelta += history.DownlinkThroughputBps[idx] / 8.0
@@ -171,13 +300,11 @@ func (bt *BandwidthTracker) processHistory(history *client.HistoryResponse) {
 		// Ping metrics: accumulate latency (convert ms to seconds) and drops
 		pingLatencyDelta += history.PopPingLatencyMs[idx] / 1000.0 // ms to seconds
 		pingDropDelta += history.PopPingDropRate[idx]
-
-		// Log first few sample indices for debugging
-		if len(sampleIndices) < 3 {
-			sampleIndices = append(sampleIndices, idx)
-		}
 	}
 
+	// Log first few sample indices for debugging
+	sampleIndices := indices[:min(len(indices), 3)]
+
 	bt.downloadBytesTotal += downloadDelta
 	bt.uploadBytesTotal += uploadDelta
 	bt.energyJoulesTotal += energyDelta
@@ -195,7 +322,18 @@ func (bt *BandwidthTracker) processHistory(history *client.HistoryResponse) {
 		"ping_sample_count", timeDelta,
 		"ping_drop_delta", pingDropDelta)
 
-	bt.lastCurrent = current
+	return samples
+}
+
+// sampleTime returns when the sample at ring buffer index idx was taken, given
+// the buffer's current counter. The sample before anchorCurrent was taken at
+// anchorTime. Must be called with bt.mu held.
+func (bt *BandwidthTracker) sampleTime(current uint64, idx, bufferLen int) time.Time {
+	// The newest counter at idx is this many samples before the newest sample
+	newestIdx := int((current - 1) % uint64(bufferLen))
+	back := (newestIdx - idx + bufferLen) % bufferLen
+	counter := current - 1 - uint64(back)
+	return bt.anchorTime.Add(time.Duration(int64(counter)-int64(bt.anchorCurrent)+1) * time.Second)
 }
 
 // GetCounters returns current bandwidth counters (thread-safe for Prometheus scrapes)
@@ -219,6 +357,105 @@ func (bt *BandwidthTracker) GetPingMetrics() (latencySum, sampleCount, dropCount
 	return bt.pingLatencySecondsSum, bt.pingLatencySampleCount, bt.pingDropCount
 }
 
+// TrackerState is a point-in-time snapshot of the bandwidth tracker
+type TrackerState struct {
+	Initialized            bool      `json:"initialized"`
+	LastCurrent            uint64    `json:"lastCurrent"`
+	DownloadBytesTotal     float64   `json:"downloadBytesTotal"`
+	UploadBytesTotal       float64   `json:"uploadBytesTotal"`
+	EnergyJoulesTotal      float64   `json:"energyJoulesTotal"`
+	PingLatencySecondsSum  float64   `json:"pingLatencySecondsSum"`
+	PingLatencySampleCount float64   `json:"pingLatencySampleCount"`
+	PingDropCount          float64   `json:"pingDropCount"`
+	LastError              string    `json:"lastError,omitempty"`
+	LastSuccess            time.Time `json:"lastSuccess"`
+}
+
+// GetState returns a consistent snapshot of all tracker counters and status
+func (bt *BandwidthTracker) GetState() TrackerState {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	state := TrackerState{
+		Initialized:            bt.initialized,
+		LastCurrent:            bt.lastCurrent,
+		DownloadBytesTotal:     bt.downloadBytesTotal,
+		UploadBytesTotal:       bt.uploadBytesTotal,
+		EnergyJoulesTotal:      bt.energyJoulesTotal,
+		PingLatencySecondsSum:  bt.pingLatencySecondsSum,
+		PingLatencySampleCount: bt.pingLatencySampleCount,
+		PingDropCount:          bt.pingDropCount,
+		LastSuccess:            bt.lastSuccess,
+	}
+	if bt.lastError != nil {
+		state.LastError = bt.lastError.Error()
+	}
+	return state
+}
+
+// Alive returns nil if the Start loop has ticked within maxAge, whether or not
+// the dish answered, or an error if the loop looks wedged. A tracker that
+// hasn't been started is alive.
+func (bt *BandwidthTracker) Alive(maxAge time.Duration) error {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	if bt.lastTick.IsZero() {
+		return nil
+	}
+	if age := time.Since(bt.lastTick); age > maxAge {
+		return fmt.Errorf("history tracker last ticked %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+// Ready returns nil if the tracker has initialized its history cursor and fetched
+// history within maxAge, or an error describing why it isn't ready
+func (bt *BandwidthTracker) Ready(maxAge time.Duration) error {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	if !bt.initialized {
+		if bt.lastError != nil {
+			return fmt.Errorf("history tracker not initialized: %v", bt.lastError)
+		}
+		return errors.New("history tracker not initialized")
+	}
+	if age := time.Since(bt.lastSuccess); age > maxAge {
+		if bt.lastError != nil {
+			return fmt.Errorf("last successful history fetch was %s ago: %v", age.Round(time.Second), bt.lastError)
+		}
+		return fmt.Errorf("last successful history fetch was %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+// Describe implements prometheus.Collector
+func (bt *BandwidthTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bt.tickLagDesc
+	ch <- bt.lastSuccessDesc
+	ch <- bt.samplesProcessedDesc
+	ch <- bt.gapsDesc
+	ch <- bt.resetsDesc
+}
+
+// Collect implements prometheus.Collector, exporting the tracker's own health
+func (bt *BandwidthTracker) Collect(ch chan<- prometheus.Metric) {
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+
+	lastSuccess := 0.0
+	if !bt.lastSuccess.IsZero() {
+		lastSuccess = float64(bt.lastSuccess.UnixNano()) / 1e9
+	}
+
+	ch <- prometheus.MustNewConstMetric(bt.tickLagDesc, prometheus.GaugeValue, bt.tickLag)
+	ch <- prometheus.MustNewConstMetric(bt.lastSuccessDesc, prometheus.GaugeValue, lastSuccess)
+	ch <- prometheus.MustNewConstMetric(bt.samplesProcessedDesc, prometheus.CounterValue, bt.stats.samples)
+	ch <- prometheus.MustNewConstMetric(bt.gapsDesc, prometheus.CounterValue, bt.stats.gaps)
+	ch <- prometheus.MustNewConstMetric(bt.resetsDesc, prometheus.CounterValue, bt.stats.resets)
+}
+
 // GetLastError returns the last error encountered (or nil if no error)
 func (bt *BandwidthTracker) GetLastError() error {
 	bt.mu.RLock()
@@ -1,11 +1,18 @@
 package collector
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/R167/starlink_exporter/internal/client"
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestBandwidthTracker_FirstUpdate(t *testing.T) {
@@ -268,3 +275,315 @@ func TestBandwidthTracker_CounterReset(t *testing.T) {
 		t.Errorf("Expected lastCurrent=500 after reset, got %d", tracker.lastCurrent)
 	}
 }
+
+func TestBandwidthTracker_Ready(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := &BandwidthTracker{logger: logger}
+
+	if err := tracker.Ready(time.Minute); err == nil {
+		t.Error("Expected tracker to not be ready before the first fetch")
+	}
+
+	tracker.processHistory(&client.HistoryResponse{
+		Current:               1000,
+		DownlinkThroughputBps: []float64{0, 0, 0},
+		UplinkThroughputBps:   []float64{0, 0, 0},
+		PowerIn:               []float64{0, 0, 0},
+		PopPingLatencyMs:      []float64{0, 0, 0},
+		PopPingDropRate:       []float64{0, 0, 0},
+	})
+	if err := tracker.Ready(time.Minute); err != nil {
+		t.Errorf("Expected tracker to be ready, got %v", err)
+	}
+
+	// Wedged: no successful fetch within maxAge
+	tracker.lastSuccess = time.Now().Add(-2 * time.Minute)
+	if err := tracker.Ready(time.Minute); err == nil {
+		t.Error("Expected tracker to not be ready after a stale fetch")
+	}
+}
+
+// unreachableClient fails every request, like a powered-off dish
+type unreachableClient struct{}
+
+func (unreachableClient) GetStatus() (*client.StatusResponse, error) {
+	return nil, errors.New("dish unreachable")
+}
+
+func (unreachableClient) GetHistory() (*client.HistoryResponse, error) {
+	return nil, errors.New("dish unreachable")
+}
+
+func TestBandwidthTracker_Alive(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewBandwidthTracker(unreachableClient{}, logger)
+	tracker.interval = 10 * time.Millisecond
+
+	if err := tracker.Alive(time.Minute); err != nil {
+		t.Errorf("Expected an unstarted tracker to be alive, got %v", err)
+	}
+
+	// The loop keeps ticking while the dish is down
+	go tracker.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	if err := tracker.Alive(time.Second); err != nil {
+		t.Errorf("Expected tracker to be alive while backing off, got %v", err)
+	}
+	tracker.Stop()
+
+	// Wedged: no tick within maxAge
+	tracker.lastTick = time.Now().Add(-2 * time.Minute)
+	if err := tracker.Alive(time.Minute); err == nil {
+		t.Error("Expected tracker to not be alive after a stale tick")
+	}
+}
+
+func TestBandwidthTracker_SelfMetrics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewBandwidthTracker(nil, logger)
+
+	newHistory := func(current uint64) *client.HistoryResponse {
+		return &client.HistoryResponse{
+			Current:               current,
+			DownlinkThroughputBps: make([]float64, 900),
+			UplinkThroughputBps:   make([]float64, 900),
+			PowerIn:               make([]float64, 900),
+			PopPingLatencyMs:      make([]float64, 900),
+			PopPingDropRate:       make([]float64, 900),
+		}
+	}
+	tracker.processHistory(newHistory(1000))
+	tracker.processHistory(newHistory(1010)) // 10 samples
+	tracker.processHistory(newHistory(5000)) // Gap: capped at 900 samples
+	tracker.processHistory(newHistory(100))  // Reset
+
+	expected := `
+# HELP starlink_exporter_tracker_gaps_total Total polls where more time passed than the history buffer holds, losing samples
+# TYPE starlink_exporter_tracker_gaps_total counter
+starlink_exporter_tracker_gaps_total 1
+# HELP starlink_exporter_tracker_resets_total Total history counter resets detected (dish restarts)
+# TYPE starlink_exporter_tracker_resets_total counter
+starlink_exporter_tracker_resets_total 1
+# HELP starlink_exporter_tracker_samples_processed_total Total history samples integrated by the history tracker
+# TYPE starlink_exporter_tracker_samples_processed_total counter
+starlink_exporter_tracker_samples_processed_total 910
+`
+	err := testutil.CollectAndCompare(tracker, strings.NewReader(expected),
+		"starlink_exporter_tracker_gaps_total",
+		"starlink_exporter_tracker_resets_total",
+		"starlink_exporter_tracker_samples_processed_total")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBandwidthTracker_Samples(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewBandwidthTracker(nil, logger)
+	tracker.AddSampleHandler(func([]HistorySample) {})
+
+	history := &client.HistoryResponse{
+		Current:               1000,
+		DownlinkThroughputBps: []float64{1, 2, 3, 4},
+		UplinkThroughputBps:   make([]float64, 4),
+		PowerIn:               []float64{40, 41, 42, 43},
+		PopPingLatencyMs:      make([]float64, 4),
+		PopPingDropRate:       make([]float64, 4),
+	}
+	if samples := tracker.processHistory(history); samples != nil {
+		t.Errorf("Expected no samples on first update, got %v", samples)
+	}
+
+	// Counters 1000 and 1001 live at indices 0 and 1
+	history.Current = 1002
+	samples := tracker.processHistory(history)
+	if len(samples) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(samples))
+	}
+	if samples[0].DownlinkThroughputBps != 1 || samples[1].PowerInWatts != 41 {
+		t.Errorf("Unexpected samples %+v", samples)
+	}
+	if samples[1].Time.Sub(samples[0].Time) != time.Second {
+		t.Errorf("Expected samples one second apart, got %v and %v", samples[0].Time, samples[1].Time)
+	}
+}
+
+func TestBandwidthTracker_SampleTimesFollowCounter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewBandwidthTracker(nil, logger)
+	tracker.AddSampleHandler(func([]HistorySample) {})
+
+	history := &client.HistoryResponse{
+		Current:               1000,
+		DownlinkThroughputBps: []float64{1, 2, 3, 4},
+		UplinkThroughputBps:   make([]float64, 4),
+		PowerIn:               make([]float64, 4),
+		PopPingLatencyMs:      make([]float64, 4),
+		PopPingDropRate:       make([]float64, 4),
+	}
+	tracker.processHistory(history)
+
+	// Two polls within the same wall-clock second, each with a new sample, as
+	// happens when polls straddle the dish's second boundary
+	var times []time.Time
+	for _, current := range []uint64{1001, 1002} {
+		history.Current = current
+		for _, s := range tracker.processHistory(history) {
+			times = append(times, s.Time)
+		}
+	}
+	if len(times) != 2 {
+		t.Fatalf("Expected 2 samples, got %d", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		if d := times[i].Sub(times[i-1]); d != time.Second {
+			t.Errorf("sample %d: %v after the previous sample, want 1s", i, d)
+		}
+	}
+	if want := tracker.anchorTime.Add(time.Second); !times[0].Equal(want) {
+		t.Errorf("Expected counter 1000 at the anchor second plus one, got %v want %v", times[0], want)
+	}
+}
+
+func TestBandwidthTracker_SampleTimesReanchor(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	newTracker := func() (*BandwidthTracker, *client.HistoryResponse) {
+		tracker := NewBandwidthTracker(nil, logger)
+		tracker.AddSampleHandler(func([]HistorySample) {})
+		history := &client.HistoryResponse{
+			Current:               1000,
+			DownlinkThroughputBps: make([]float64, 4),
+			UplinkThroughputBps:   make([]float64, 4),
+			PowerIn:               make([]float64, 4),
+			PopPingLatencyMs:      make([]float64, 4),
+			PopPingDropRate:       make([]float64, 4),
+		}
+		tracker.processHistory(history)
+		return tracker, history
+	}
+	checkNewest := func(t *testing.T, tracker *BandwidthTracker, samples []HistorySample) {
+		t.Helper()
+		if len(samples) == 0 {
+			t.Fatal("Expected samples")
+		}
+		newest := samples[len(samples)-1].Time
+		if now := tracker.lastSuccess.Truncate(time.Second); !newest.Equal(now) {
+			t.Errorf("Expected the newest sample at the fetch second %v, got %v", now, newest)
+		}
+	}
+
+	t.Run("reboot with a higher counter", func(t *testing.T) {
+		// The dish rebooted between polls and counted past where it was, so
+		// the cursor sees a gap rather than a reset
+		tracker, history := newTracker()
+		history.Current = 50000
+		checkNewest(t, tracker, tracker.processHistory(history))
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		// The counter has fallen behind the clock since the anchor was taken
+		tracker, history := newTracker()
+		tracker.anchorTime = tracker.anchorTime.Add(-10 * time.Second)
+		history.Current = 1001
+		checkNewest(t, tracker, tracker.processHistory(history))
+	})
+}
+
+func TestBandwidthTracker_SamplesAfterGapInOrder(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewBandwidthTracker(nil, logger)
+	tracker.AddSampleHandler(func([]HistorySample) {})
+
+	// Counters 1002..1005 live at indices 2, 3, 0, 1
+	history := &client.HistoryResponse{
+		Current:               1000,
+		DownlinkThroughputBps: []float64{1004, 1005, 1002, 1003},
+		UplinkThroughputBps:   make([]float64, 4),
+		PowerIn:               make([]float64, 4),
+		PopPingLatencyMs:      make([]float64, 4),
+		PopPingDropRate:       make([]float64, 4),
+	}
+	tracker.processHistory(history)
+
+	// More than a buffer's worth of samples since the last poll
+	history.Current = 1006
+	samples := tracker.processHistory(history)
+	if len(samples) != 4 {
+		t.Fatalf("Expected the whole buffer after a gap, got %d samples", len(samples))
+	}
+	for i, s := range samples {
+		if want := float64(1002 + i); s.DownlinkThroughputBps != want {
+			t.Errorf("sample %d: downlink = %v, want %v", i, s.DownlinkThroughputBps, want)
+		}
+		if i > 0 && !s.Time.After(samples[i-1].Time) {
+			t.Errorf("sample %d: time %v not after the previous sample's %v", i, s.Time, samples[i-1].Time)
+		}
+	}
+}
+
+func TestBandwidthTracker_Replay(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	// A 4-second ring buffer at a constant 8000 bps, so every integrated sample
+	// adds 1000 bytes
+	path := filepath.Join(t.TempDir(), "dish.rec")
+	rec, err := client.NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1000, 0)
+	for i, current := range []uint64{10, 12, 13, 13, 20, 3, 5, 6} {
+		uplink := []float32{0, 0, 0, 0}
+		if current == 6 {
+			uplink = uplink[:3] // Length mismatch, skipped
+		}
+		resp := &pb.Response{Response: &pb.Response_DishGetHistory{DishGetHistory: &pb.DishGetHistoryResponse{
+			Current:               current,
+			DownlinkThroughputBps: []float32{8000, 8000, 8000, 8000},
+			UplinkThroughputBps:   uplink,
+			PopPingLatencyMs:      []float32{20, 20, 20, 20},
+			PopPingDropRate:       []float32{0, 0, 0, 0},
+			PowerIn:               []float32{50, 50, 50, 50},
+		}}}
+		if err := rec.Record(start.Add(time.Duration(i)*time.Second), "get_history", resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec.Close()
+
+	records, err := client.LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := client.NewReplayClient(records, 0)
+	tracker := NewBandwidthTracker(replay, logger)
+	for range records {
+		tracker.update()
+	}
+	tracker.update()
+	if !errors.Is(tracker.GetLastError(), client.ErrReplayFinished) {
+		t.Errorf("Expected the replay to be finished, got %v", tracker.GetLastError())
+	}
+
+	// 2 + 1 + 0 + 4 (gap, capped to the buffer) + 0 (reset) + 2 samples
+	state := tracker.GetState()
+	if state.DownloadBytesTotal != 9000 {
+		t.Errorf("Expected 9000 download bytes, got %f", state.DownloadBytesTotal)
+	}
+	if state.EnergyJoulesTotal != 450 {
+		t.Errorf("Expected 450 J, got %f", state.EnergyJoulesTotal)
+	}
+	if state.LastCurrent != 5 {
+		t.Errorf("Expected lastCurrent=5 after the mismatched response, got %d", state.LastCurrent)
+	}
+
+	expected := `
+# HELP starlink_exporter_tracker_gaps_total Total polls where more time passed than the history buffer holds, losing samples
+# TYPE starlink_exporter_tracker_gaps_total counter
+starlink_exporter_tracker_gaps_total 1
+`
+	if err := testutil.CollectAndCompare(tracker, strings.NewReader(expected), "starlink_exporter_tracker_gaps_total"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+// historyCursor tracks the read position in a circular history buffer.
+//
+// Starlink devices report history as fixed-length ring buffers together with a
+// monotonically increasing Current counter; the sample for counter value t lives
+// at index t % len. The cursor remembers the last counter it consumed so each
+// poll only integrates samples that have not been seen yet.
+type historyCursor struct {
+	lastCurrent uint64 // Last seen history timestamp
+	initialized bool
+	stats       cursorStats
+}
+
+// cursorStats counts what the cursor has consumed, for self-instrumentation
+type cursorStats struct {
+	samples float64 // Samples returned by newSamples
+	gaps    float64 // Polls where the delta exceeded the buffer and samples were lost
+	resets  float64 // Counter resets (device restarts)
+}
+
+// newSamples advances the cursor to current and returns the ring buffer indices
+// of samples written since the previous call, oldest first. It returns nothing on
+// the first call, when the counter goes backwards (device restart), or when no
+// time has passed. Gaps longer than the buffer are capped to the buffer length,
+// returning the whole buffer.
+func (hc *historyCursor) newSamples(current uint64, bufferLen int, logger *slog.Logger) []int {
+	// On first run, just record the current timestamp
+	if !hc.initialized {
+		hc.lastCurrent = current
+		hc.initialized = true
+		logger.Info("History tracker initialized", "current", current)
+		return nil
+	}
+
+	// Detect counter reset (dishy restart)
+	if current < hc.lastCurrent {
+		logger.Warn("Counter reset detected (dishy restart?)",
+			"previous", hc.lastCurrent,
+			"current", current)
+		hc.lastCurrent = current
+		hc.stats.resets++
+		// Don't reset counters - keep accumulating across restarts
+		return nil
+	}
+
+	// Calculate how many new samples we have
+	timeDelta := current - hc.lastCurrent
+
+	if timeDelta == 0 {
+		// No new data yet
+		return nil
+	}
+
+	// Current timestamp tells us which index is "now": index = Current % arrayLength
+	length := uint64(bufferLen)
+
+	// Cap timeDelta to avoid processing more than the buffer size
+	if timeDelta > length {
+		logger.Warn("Time delta exceeds history buffer size, possible data loss",
+			"delta", timeDelta,
+			"buffer_size", length)
+		timeDelta = length
+		hc.stats.gaps++
+	}
+
+	// Walk the circular buffer from current-timeDelta to current-1. After a gap
+	// that is the whole buffer, oldest first, not from lastCurrent, whose slot
+	// has since been overwritten by a newer sample.
+	first := current - timeDelta
+	indices := make([]int, timeDelta)
+	for i := uint64(0); i < timeDelta; i++ {
+		indices[i] = int((first + i) % length)
+	}
+
+	hc.lastCurrent = current
+	hc.stats.samples += float64(timeDelta)
+	return indices
+}
+
+// Chronological returns every sample in history oldest first, timestamped on the
+// assumption that the newest was taken at newest. The sample for counter value
+// Current-1 is the latest; a buffer that hasn't wrapped yet holds only Current
+// samples.
+func Chronological(history *client.HistoryResponse, newest time.Time) ([]HistorySample, error) {
+	length := len(history.DownlinkThroughputBps)
+	if len(history.UplinkThroughputBps) != length ||
+		len(history.PowerIn) != length ||
+		len(history.PopPingLatencyMs) != length ||
+		len(history.PopPingDropRate) != length {
+		return nil, fmt.Errorf("history array length mismatch (downlink %d, uplink %d, power %d, ping latency %d, ping drop %d)",
+			length, len(history.UplinkThroughputBps), len(history.PowerIn),
+			len(history.PopPingLatencyMs), len(history.PopPingDropRate))
+	}
+
+	count := min(history.Current, uint64(length))
+	first := history.Current - count
+	samples := make([]HistorySample, count)
+	for i := range samples {
+		idx := (first + uint64(i)) % uint64(length)
+		samples[i] = HistorySample{
+			Time:                  newest.Add(-time.Duration(int(count)-1-i) * time.Second),
+			DownlinkThroughputBps: history.DownlinkThroughputBps[idx],
+			UplinkThroughputBps:   history.UplinkThroughputBps[idx],
+			PopPingLatencyMs:      history.PopPingLatencyMs[idx],
+			PopPingDropRate:       history.PopPingDropRate[idx],
+			PowerInWatts:          history.PowerIn[idx],
+		}
+	}
+	return samples, nil
+}
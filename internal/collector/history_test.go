@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+func TestChronological(t *testing.T) {
+	newest := time.Unix(1700000000, 0)
+
+	// Current=1002 with 4 slots: counters 998..1001 live at indices 2, 3, 0, 1
+	history := &client.HistoryResponse{
+		Current:               1002,
+		DownlinkThroughputBps: []float64{1000, 1001, 998, 999},
+		UplinkThroughputBps:   make([]float64, 4),
+		PowerIn:               make([]float64, 4),
+		PopPingLatencyMs:      make([]float64, 4),
+		PopPingDropRate:       make([]float64, 4),
+	}
+	samples, err := Chronological(history, newest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 4 {
+		t.Fatalf("got %d samples, want 4", len(samples))
+	}
+	for i, s := range samples {
+		if want := float64(998 + i); s.DownlinkThroughputBps != want {
+			t.Errorf("sample %d: downlink = %v, want %v", i, s.DownlinkThroughputBps, want)
+		}
+		if want := newest.Add(time.Duration(i-3) * time.Second); !s.Time.Equal(want) {
+			t.Errorf("sample %d: time = %v, want %v", i, s.Time, want)
+		}
+	}
+
+	// A buffer that hasn't wrapped only holds Current samples
+	history.Current = 2
+	samples, err = Chronological(history, newest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].DownlinkThroughputBps != 1000 || samples[1].DownlinkThroughputBps != 1001 {
+		t.Errorf("unwrapped buffer: got %+v", samples)
+	}
+
+	history.PowerIn = history.PowerIn[:3]
+	if _, err := Chronological(history, newest); err == nil {
+		t.Error("expected an error for mismatched array lengths")
+	}
+}
@@ -0,0 +1,220 @@
+package collector
+
+import (
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FieldMapper turns the fields of any protobuf message into gauges, so fields
+// new firmware adds can be exported without a hand-written Desc. Fields are
+// addressed by their dotted path from the message root, e.g.
+// "obstruction_stats.fraction_obstructed", and named after it with the dots
+// replaced by underscores.
+//
+// Numeric fields become gauges and bools 0/1 gauges. Enums become state sets:
+// one series per value with a "state" label, 1 for the current value. Nested
+// messages are walked when set; strings, bytes, repeated and map fields are
+// skipped, as are unset optional fields.
+//
+// A field whose metric name is reserved or already taken by an earlier field
+// is skipped with a warning, as the clash would fail the whole scrape.
+type FieldMapper struct {
+	prefix string
+	allow  []string
+	deny   []string
+	rename map[string]string
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	descs    map[string]*prometheus.Desc // By field path; nil for skipped fields
+	names    map[string]string           // Field path by metric name
+	reserved map[string]bool             // Metric names other collectors export
+}
+
+// NewFieldMapper creates a mapper naming metrics prefix plus the field path.
+// allow and deny hold path.Match patterns against field paths; a pattern also
+// matches everything below the fields it matches. An empty allow list allows
+// every field, and deny wins over allow. rename maps field paths to full metric
+// names, replacing the derived name.
+func NewFieldMapper(prefix string, allow, deny []string, rename map[string]string, logger *slog.Logger) *FieldMapper {
+	return &FieldMapper{
+		prefix:   prefix,
+		allow:    allow,
+		deny:     deny,
+		rename:   rename,
+		logger:   logger,
+		descs:    make(map[string]*prometheus.Desc),
+		names:    make(map[string]string),
+		reserved: make(map[string]bool),
+	}
+}
+
+// Reserve keeps the mapper from naming a field after any of names, typically
+// the metrics other collectors export
+func (fm *FieldMapper) Reserve(names ...string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for _, name := range names {
+		fm.reserved[name] = true
+	}
+}
+
+// Map sends a gauge for every mapped field of m
+func (fm *FieldMapper) Map(m protoreflect.Message, ch chan<- prometheus.Metric) {
+	fm.walk(m, m.Descriptor().Name(), "", ch)
+}
+
+// walk maps the fields of m, whose path from the root message is parent
+func (fm *FieldMapper) walk(m protoreflect.Message, root protoreflect.Name, parent string, ch chan<- prometheus.Metric) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsList() || fd.IsMap() || (fd.HasPresence() && !m.Has(fd)) {
+			continue
+		}
+		fieldPath := string(fd.Name())
+		if parent != "" {
+			fieldPath = parent + "." + fieldPath
+		}
+		// Allowed fields may sit below a message the allow list doesn't name,
+		// so only deny can prune a subtree
+		if matchesAny(fm.deny, fieldPath) {
+			continue
+		}
+		v := m.Get(fd)
+
+		switch fd.Kind() {
+		case protoreflect.MessageKind, protoreflect.GroupKind:
+			fm.walk(v.Message(), root, fieldPath, ch)
+			continue
+		case protoreflect.StringKind, protoreflect.BytesKind:
+			continue
+		}
+		if len(fm.allow) > 0 && !matchesAny(fm.allow, fieldPath) {
+			continue
+		}
+
+		var labels []string
+		if fd.Kind() == protoreflect.EnumKind {
+			labels = []string{"state"}
+		}
+		desc := fm.desc(root, fieldPath, fd, labels)
+		if desc == nil {
+			continue
+		}
+
+		switch fd.Kind() {
+		case protoreflect.EnumKind:
+			current := v.Enum()
+			values := fd.Enum().Values()
+			for j := 0; j < values.Len(); j++ {
+				ev := values.Get(j)
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat(ev.Number() == current), string(ev.Name()))
+			}
+		case protoreflect.BoolKind:
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat(v.Bool()))
+		default:
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, numericValue(fd.Kind(), v))
+		}
+	}
+}
+
+// desc returns the Desc for the field at fieldPath, creating it on first use. It
+// returns nil if the field's metric name is reserved or taken.
+func (fm *FieldMapper) desc(root protoreflect.Name, fieldPath string, fd protoreflect.FieldDescriptor, labels []string) *prometheus.Desc {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if desc, ok := fm.descs[fieldPath]; ok {
+		return desc
+	}
+
+	name, ok := fm.rename[fieldPath]
+	if !ok {
+		name = fm.prefix + strings.ReplaceAll(fieldPath, ".", "_")
+	}
+	if other, taken := fm.names[name]; taken || fm.reserved[name] {
+		if taken {
+			fm.logger.Warn("Skipping status field, its metric name is taken by another field", "field", fieldPath, "metric", name, "other", other)
+		} else {
+			fm.logger.Warn("Skipping status field, its metric name is taken by another collector", "field", fieldPath, "metric", name)
+		}
+		fm.descs[fieldPath] = nil
+		return nil
+	}
+	fm.names[name] = fieldPath
+	help := string(root) + " field " + fieldPath
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		help += " (1 for the current " + string(fd.Enum().Name()) + ")"
+	case protoreflect.BoolKind:
+		help += " (1 = true, 0 = false)"
+	}
+	desc := prometheus.NewDesc(name, help, labels, nil)
+	fm.descs[fieldPath] = desc
+	return desc
+}
+
+// descNameRE extracts the name from a Desc's String form; Desc doesn't export it
+var descNameRE = regexp.MustCompile(`^Desc\{fqName: "([^"]*)"`)
+
+// DescribedNames returns the names of the metrics collectors describe
+func DescribedNames(collectors ...prometheus.Collector) []string {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		for _, c := range collectors {
+			c.Describe(ch)
+		}
+		close(ch)
+	}()
+
+	var names []string
+	for desc := range ch {
+		if m := descNameRE.FindStringSubmatch(desc.String()); m != nil {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// matchesAny reports whether fieldPath or one of its parents matches a pattern
+func matchesAny(patterns []string, fieldPath string) bool {
+	for _, pattern := range patterns {
+		for p := fieldPath; ; {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+			i := strings.LastIndexByte(p, '.')
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+	}
+	return false
+}
+
+// numericValue converts a scalar protobuf value to float64
+func numericValue(kind protoreflect.Kind, v protoreflect.Value) float64 {
+	switch kind {
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(v.Uint())
+	default:
+		return float64(v.Int())
+	}
+}
+
+// boolToFloat returns 1 for true and 0 for false
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeRawStatusClient struct {
+	raw *pb.DishGetStatusResponse
+	err error
+}
+
+func (f *fakeRawStatusClient) GetStatus() (*client.StatusResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &client.StatusResponse{Raw: f.raw}, nil
+}
+
+func (f *fakeRawStatusClient) GetHistory() (*client.HistoryResponse, error) {
+	return &client.HistoryResponse{}, nil
+}
+
+func TestFieldMapper(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeRawStatusClient{raw: &pb.DishGetStatusResponse{
+		ObstructionStats: &pb.DishObstructionStats{
+			CurrentlyObstructed:              true,
+			FractionObstructed:               0.25,
+			ValidS:                           600,
+			AvgProlongedObstructionDurationS: 3,
+		},
+		GpsStats:         &pb.DishGpsStats{GpsValid: true, GpsSats: 12},
+		MobilityClass:    pb.UserMobilityClass_NOMADIC,
+		EthSpeedMbps:     1000,
+		PopPingDropRate:  0.5,
+		PopPingLatencyMs: 30,
+	}}
+	mapper := NewFieldMapper("starlink_status_",
+		[]string{"obstruction_stats", "gps_stats.gps_sats", "mobility_class", "eth_speed_mbps", "pop_ping_*"},
+		[]string{"obstruction_stats.avg_*", "obstruction_stats.patches_valid", "pop_ping_latency_ms"},
+		map[string]string{"eth_speed_mbps": "starlink_status_ethernet_speed_mbps"}, logger)
+	collector := NewStatusFieldsCollector(fake, mapper, logger)
+
+	expected := `
+# HELP starlink_status_obstruction_stats_currently_obstructed DishGetStatusResponse field obstruction_stats.currently_obstructed (1 = true, 0 = false)
+# TYPE starlink_status_obstruction_stats_currently_obstructed gauge
+starlink_status_obstruction_stats_currently_obstructed 1
+# HELP starlink_status_obstruction_stats_fraction_obstructed DishGetStatusResponse field obstruction_stats.fraction_obstructed
+# TYPE starlink_status_obstruction_stats_fraction_obstructed gauge
+starlink_status_obstruction_stats_fraction_obstructed 0.25
+# HELP starlink_status_obstruction_stats_time_obstructed DishGetStatusResponse field obstruction_stats.time_obstructed
+# TYPE starlink_status_obstruction_stats_time_obstructed gauge
+starlink_status_obstruction_stats_time_obstructed 0
+# HELP starlink_status_obstruction_stats_valid_s DishGetStatusResponse field obstruction_stats.valid_s
+# TYPE starlink_status_obstruction_stats_valid_s gauge
+starlink_status_obstruction_stats_valid_s 600
+# HELP starlink_status_gps_stats_gps_sats DishGetStatusResponse field gps_stats.gps_sats
+# TYPE starlink_status_gps_stats_gps_sats gauge
+starlink_status_gps_stats_gps_sats 12
+# HELP starlink_status_mobility_class DishGetStatusResponse field mobility_class (1 for the current UserMobilityClass)
+# TYPE starlink_status_mobility_class gauge
+starlink_status_mobility_class{state="MOBILE"} 0
+starlink_status_mobility_class{state="NOMADIC"} 1
+starlink_status_mobility_class{state="STATIONARY"} 0
+# HELP starlink_status_ethernet_speed_mbps DishGetStatusResponse field eth_speed_mbps
+# TYPE starlink_status_ethernet_speed_mbps gauge
+starlink_status_ethernet_speed_mbps 1000
+# HELP starlink_status_pop_ping_drop_rate DishGetStatusResponse field pop_ping_drop_rate
+# TYPE starlink_status_pop_ping_drop_rate gauge
+starlink_status_pop_ping_drop_rate 0.5
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"starlink_status_obstruction_stats_currently_obstructed",
+		"starlink_status_obstruction_stats_fraction_obstructed",
+		"starlink_status_obstruction_stats_time_obstructed",
+		"starlink_status_obstruction_stats_valid_s",
+		"starlink_status_obstruction_stats_patches_valid",
+		"starlink_status_obstruction_stats_avg_prolonged_obstruction_duration_s",
+		"starlink_status_gps_stats_gps_sats",
+		"starlink_status_gps_stats_gps_valid",
+		"starlink_status_mobility_class",
+		"starlink_status_eth_speed_mbps",
+		"starlink_status_ethernet_speed_mbps",
+		"starlink_status_pop_ping_drop_rate",
+		"starlink_status_pop_ping_latency_ms")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Unset messages are skipped, so only the top-level scalars remain
+	all := NewStatusFieldsCollector(fake, NewFieldMapper("starlink_status_", nil, []string{"obstruction_stats", "gps_stats"}, nil, logger), logger)
+	if n := testutil.CollectAndCount(all, "starlink_status_device_info_bootcount", "starlink_status_obstruction_stats_valid_s"); n != 0 {
+		t.Errorf("Expected no metrics for unset or denied messages, got %d", n)
+	}
+	if n := testutil.CollectAndCount(all, "starlink_status_pop_ping_latency_ms"); n != 1 {
+		t.Errorf("Expected every field with an empty allow list, got %d pop_ping_latency_ms series", n)
+	}
+}
+
+func TestFieldMapper_SkipsCollisions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeRawStatusClient{raw: &pb.DishGetStatusResponse{
+		DeviceState:      &pb.DeviceState{UptimeS: 3600},
+		EthSpeedMbps:     1000,
+		PopPingDropRate:  0.5,
+		PopPingLatencyMs: 30,
+	}}
+	mapper := NewFieldMapper("starlink_status_", []string{"device_state", "eth_speed_mbps", "pop_ping_*"}, nil,
+		map[string]string{
+			"device_state.uptime_s": "starlink_uptime_seconds",            // Another collector's metric
+			"pop_ping_latency_ms":   "starlink_status_pop_ping_drop_rate", // Another field's derived name
+			"eth_speed_mbps":        "starlink_ethernet_speed_mbps",
+		}, logger)
+	starlink := NewStarlinkCollector(fake, nil, logger)
+	mapper.Reserve(DescribedNames(starlink)...)
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(NewStatusFieldsCollector(fake, mapper, logger))
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "starlink_uptime_seconds",
+		Help: "Stands in for StarlinkCollector's uptime gauge",
+	}, func() float64 { return 3600 }))
+
+	expected := `
+# HELP starlink_uptime_seconds Stands in for StarlinkCollector's uptime gauge
+# TYPE starlink_uptime_seconds gauge
+starlink_uptime_seconds 3600
+# HELP starlink_ethernet_speed_mbps DishGetStatusResponse field eth_speed_mbps
+# TYPE starlink_ethernet_speed_mbps gauge
+starlink_ethernet_speed_mbps 1000
+# HELP starlink_status_pop_ping_drop_rate DishGetStatusResponse field pop_ping_drop_rate
+# TYPE starlink_status_pop_ping_drop_rate gauge
+starlink_status_pop_ping_drop_rate 0.5
+`
+	// Scraped twice, as the collisions are remembered
+	for range 2 {
+		if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestStatusFieldsCollector_Error(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeRawStatusClient{err: errors.New("unavailable")}
+	collector := NewStatusFieldsCollector(fake, NewFieldMapper("starlink_status_", nil, nil, nil, logger), logger)
+	if err := collector.Update(make(chan prometheus.Metric, 1)); err == nil {
+		t.Error("Expected the status error to fail the update")
+	}
+}
@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MeshCollector collects mesh membership and backhaul metrics from a Starlink router
+type MeshCollector struct {
+	client client.MeshClient
+	logger *slog.Logger
+
+	// Gauges - Membership
+	nodes              *prometheus.Desc
+	nodeInfo           *prometheus.Desc
+	hopsFromController *prometheus.Desc
+	estTxRateMbps      *prometheus.Desc
+	estRxRateMbps      *prometheus.Desc
+	signalStrength     *prometheus.Desc
+
+	// Gauges - Backhaul
+	backhaulSuccess       *prometheus.Desc
+	backhaulPreference    *prometheus.Desc
+	backhaulRssi          *prometheus.Desc
+	backhaulEstRxRateMbps *prometheus.Desc
+
+	// Status
+	up *prometheus.Desc
+}
+
+// NewMeshCollector creates a new mesh collector
+func NewMeshCollector(c client.MeshClient, logger *slog.Logger) *MeshCollector {
+	nodeLabels := []string{"node_id"}
+
+	return &MeshCollector{
+		client: c,
+		logger: logger,
+
+		// Gauges - Membership
+		nodes: prometheus.NewDesc(
+			"starlink_mesh_nodes",
+			"Number of mesh routers (controller and repeaters) in the mesh",
+			nil, nil,
+		),
+		nodeInfo: prometheus.NewDesc(
+			"starlink_mesh_node_info",
+			"Mesh router information",
+			[]string{"node_id", "name", "mac_address", "role", "hardware_version", "software_version"}, nil,
+		),
+		hopsFromController: prometheus.NewDesc(
+			"starlink_mesh_node_hops_from_controller",
+			"Number of wireless hops between the mesh router and the controller",
+			nodeLabels, nil,
+		),
+		estTxRateMbps: prometheus.NewDesc(
+			"starlink_mesh_node_est_tx_rate_mbps",
+			"Estimated transmit rate from the controller to the mesh router in Mbps",
+			nodeLabels, nil,
+		),
+		estRxRateMbps: prometheus.NewDesc(
+			"starlink_mesh_node_est_rx_rate_mbps",
+			"Estimated receive rate from the mesh router to the controller in Mbps",
+			nodeLabels, nil,
+		),
+		signalStrength: prometheus.NewDesc(
+			"starlink_mesh_node_signal_strength_dbm",
+			"Signal strength of the mesh router backhaul in dBm",
+			nodeLabels, nil,
+		),
+
+		// Gauges - Backhaul
+		backhaulSuccess: prometheus.NewDesc(
+			"starlink_mesh_backhaul_success",
+			"Whether the router has an established backhaul (1 = yes, 0 = no)",
+			[]string{"bssid", "iface"}, nil,
+		),
+		backhaulPreference: prometheus.NewDesc(
+			"starlink_mesh_backhaul_preference",
+			"Estimated preference score of the current backhaul",
+			[]string{"bssid", "iface"}, nil,
+		),
+		backhaulRssi: prometheus.NewDesc(
+			"starlink_mesh_backhaul_candidate_rssi",
+			"RSSI of upstream access points seen by the backhaul site survey in dBm",
+			[]string{"bssid", "ssid", "channel"}, nil,
+		),
+		backhaulEstRxRateMbps: prometheus.NewDesc(
+			"starlink_mesh_backhaul_candidate_est_rx_rate_mbps",
+			"Estimated receive rate from upstream access points seen by the backhaul site survey in Mbps",
+			[]string{"bssid", "ssid", "channel"}, nil,
+		),
+
+		// Status
+		up: prometheus.NewDesc(
+			"starlink_mesh_up",
+			"Whether the last scrape of Starlink mesh metrics was successful (1 = success, 0 = failure)",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *MeshCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.nodes
+	ch <- c.nodeInfo
+	ch <- c.hopsFromController
+	ch <- c.estTxRateMbps
+	ch <- c.estRxRateMbps
+	ch <- c.signalStrength
+	ch <- c.backhaulSuccess
+	ch <- c.backhaulPreference
+	ch <- c.backhaulRssi
+	ch <- c.backhaulEstRxRateMbps
+	ch <- c.up
+}
+
+// Collect implements prometheus.Collector
+func (c *MeshCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Update(ch) // Failures are reported through starlink_mesh_up
+}
+
+// Update sends the mesh metrics to ch and returns the error that failed the scrape, if any
+func (c *MeshCollector) Update(ch chan<- prometheus.Metric) error {
+	status, err := c.client.GetMeshStatus()
+	if err != nil {
+		c.logger.Error("Failed to get mesh status", "error", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0.0)
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1.0)
+	ch <- prometheus.MustNewConstMetric(c.nodes, prometheus.GaugeValue, float64(len(status.Nodes)))
+
+	for _, node := range status.Nodes {
+		ch <- prometheus.MustNewConstMetric(
+			c.nodeInfo,
+			prometheus.GaugeValue,
+			1.0,
+			node.ID,
+			node.Name,
+			node.MacAddress,
+			node.Role,
+			node.HardwareVersion,
+			node.SoftwareVersion,
+		)
+		ch <- prometheus.MustNewConstMetric(c.hopsFromController, prometheus.GaugeValue, float64(node.HopsFromController), node.ID)
+		ch <- prometheus.MustNewConstMetric(c.estTxRateMbps, prometheus.GaugeValue, node.EstTxRateMbps, node.ID)
+		ch <- prometheus.MustNewConstMetric(c.estRxRateMbps, prometheus.GaugeValue, node.EstRxRateMbps, node.ID)
+		ch <- prometheus.MustNewConstMetric(c.signalStrength, prometheus.GaugeValue, node.SignalStrength, node.ID)
+	}
+
+	// Backhaul stats are only meaningful on a repeater; a controller wired to the
+	// dish may not answer, which doesn't make the mesh scrape a failure
+	backhaul, err := c.client.GetBackhaulStats()
+	if err != nil {
+		c.logger.Debug("Failed to get backhaul stats", "error", err)
+		return nil
+	}
+
+	backhaulSuccess := 0.0
+	if backhaul.Success {
+		backhaulSuccess = 1.0
+	}
+	ch <- prometheus.MustNewConstMetric(c.backhaulSuccess, prometheus.GaugeValue, backhaulSuccess, backhaul.Bssid, backhaul.Iface)
+	ch <- prometheus.MustNewConstMetric(c.backhaulPreference, prometheus.GaugeValue, float64(backhaul.Preference), backhaul.Bssid, backhaul.Iface)
+
+	seen := make(map[string]bool, len(backhaul.Candidates))
+	for _, candidate := range backhaul.Candidates {
+		channel := strconv.FormatUint(uint64(candidate.Channel), 10)
+		key := candidate.Bssid + "/" + candidate.Ssid + "/" + channel
+		if seen[key] {
+			// Site surveys can list the same BSS more than once
+			continue
+		}
+		seen[key] = true
+
+		ch <- prometheus.MustNewConstMetric(c.backhaulRssi, prometheus.GaugeValue, candidate.Rssi, candidate.Bssid, candidate.Ssid, channel)
+		ch <- prometheus.MustNewConstMetric(c.backhaulEstRxRateMbps, prometheus.GaugeValue, candidate.EstRxRateMbps, candidate.Bssid, candidate.Ssid, channel)
+	}
+
+	c.logger.Debug("Mesh scrape completed", "nodes", len(status.Nodes), "backhaul_candidates", len(backhaul.Candidates))
+	return nil
+}
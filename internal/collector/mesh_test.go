@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeMeshClient struct {
+	status      *client.MeshStatusResponse
+	backhaul    *client.BackhaulStatsResponse
+	backhaulErr error
+}
+
+func (f *fakeMeshClient) GetMeshStatus() (*client.MeshStatusResponse, error) {
+	return f.status, nil
+}
+
+func (f *fakeMeshClient) GetBackhaulStats() (*client.BackhaulStatsResponse, error) {
+	return f.backhaul, f.backhaulErr
+}
+
+func TestMeshCollector_Nodes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeMeshClient{
+		status: &client.MeshStatusResponse{
+			Nodes: []client.MeshNode{
+				{ID: "Router-01", Role: "repeater", HopsFromController: 1, EstTxRateMbps: 400},
+				{ID: "Router-02", Role: "repeater", HopsFromController: 2, EstTxRateMbps: 150},
+			},
+		},
+		backhaulErr: errors.New("not a repeater"),
+	}
+	collector := NewMeshCollector(fake, logger)
+
+	expected := `
+# HELP starlink_mesh_nodes Number of mesh routers (controller and repeaters) in the mesh
+# TYPE starlink_mesh_nodes gauge
+starlink_mesh_nodes 2
+# HELP starlink_mesh_node_hops_from_controller Number of wireless hops between the mesh router and the controller
+# TYPE starlink_mesh_node_hops_from_controller gauge
+starlink_mesh_node_hops_from_controller{node_id="Router-01"} 1
+starlink_mesh_node_hops_from_controller{node_id="Router-02"} 2
+# HELP starlink_mesh_up Whether the last scrape of Starlink mesh metrics was successful (1 = success, 0 = failure)
+# TYPE starlink_mesh_up gauge
+starlink_mesh_up 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"starlink_mesh_nodes",
+		"starlink_mesh_node_hops_from_controller",
+		"starlink_mesh_up",
+		"starlink_mesh_backhaul_success")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMeshCollector_Backhaul(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeMeshClient{
+		status: &client.MeshStatusResponse{},
+		backhaul: &client.BackhaulStatsResponse{
+			Success:    true,
+			Bssid:      "aa:bb:cc:dd:ee:ff",
+			Iface:      "rf_5ghz",
+			Preference: 80,
+			Candidates: []client.BackhaulCandidate{
+				{Bssid: "aa:bb:cc:dd:ee:ff", Ssid: "mesh", Channel: 36, Rssi: -55},
+				{Bssid: "aa:bb:cc:dd:ee:ff", Ssid: "mesh", Channel: 36, Rssi: -56},
+			},
+		},
+	}
+	collector := NewMeshCollector(fake, logger)
+
+	expected := `
+# HELP starlink_mesh_backhaul_success Whether the router has an established backhaul (1 = yes, 0 = no)
+# TYPE starlink_mesh_backhaul_success gauge
+starlink_mesh_backhaul_success{bssid="aa:bb:cc:dd:ee:ff",iface="rf_5ghz"} 1
+# HELP starlink_mesh_backhaul_candidate_rssi RSSI of upstream access points seen by the backhaul site survey in dBm
+# TYPE starlink_mesh_backhaul_candidate_rssi gauge
+starlink_mesh_backhaul_candidate_rssi{bssid="aa:bb:cc:dd:ee:ff",channel="36",ssid="mesh"} -55
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"starlink_mesh_backhaul_success",
+		"starlink_mesh_backhaul_candidate_rssi")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,206 @@
+package collector
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RouterCollector collects per-band radio and history metrics from a Starlink router
+type RouterCollector struct {
+	client         client.RouterClient
+	logger         *slog.Logger
+	historyTracker *RouterHistoryTracker
+
+	// Counters - History
+	pingLatencySecondsSum   *prometheus.Desc
+	pingLatencySecondsCount *prometheus.Desc
+	pingDropTotal           *prometheus.Desc
+	dnsResolverDropTotal    *prometheus.Desc
+
+	// Counters - Radio
+	rxBytesTotal       *prometheus.Desc
+	rxPacketsTotal     *prometheus.Desc
+	rxFrameErrorsTotal *prometheus.Desc
+	txBytesTotal       *prometheus.Desc
+	txPacketsTotal     *prometheus.Desc
+
+	// Gauges
+	thermalLevel   *prometheus.Desc
+	temperature    *prometheus.Desc
+	powerReduction *prometheus.Desc
+	dutyCycle      *prometheus.Desc
+	antennaRssi    *prometheus.Desc
+
+	// Status
+	up *prometheus.Desc
+}
+
+// NewRouterCollector creates a new router collector
+func NewRouterCollector(c client.RouterClient, tracker *RouterHistoryTracker, logger *slog.Logger) *RouterCollector {
+	bandLabels := []string{"band"}
+
+	return &RouterCollector{
+		client:         c,
+		logger:         logger,
+		historyTracker: tracker,
+
+		// Counters - History
+		pingLatencySecondsSum: prometheus.NewDesc(
+			"starlink_router_ping_latency_seconds_sum",
+			"Sum of router ping latencies in seconds (summary metric)",
+			nil, nil,
+		),
+		pingLatencySecondsCount: prometheus.NewDesc(
+			"starlink_router_ping_latency_seconds_count",
+			"Count of router ping samples (summary metric)",
+			nil, nil,
+		),
+		pingDropTotal: prometheus.NewDesc(
+			"starlink_router_ping_drop_total",
+			"Total router ping drops",
+			nil, nil,
+		),
+		dnsResolverDropTotal: prometheus.NewDesc(
+			"starlink_router_dns_resolver_drop_total",
+			"Total failed 1-second DNS resolver probes",
+			[]string{"resolver"}, nil,
+		),
+
+		// Counters - Radio
+		rxBytesTotal: prometheus.NewDesc(
+			"starlink_router_radio_rx_bytes_total",
+			"Total bytes received by the router radio",
+			bandLabels, nil,
+		),
+		rxPacketsTotal: prometheus.NewDesc(
+			"starlink_router_radio_rx_packets_total",
+			"Total packets received by the router radio",
+			bandLabels, nil,
+		),
+		rxFrameErrorsTotal: prometheus.NewDesc(
+			"starlink_router_radio_rx_frame_errors_total",
+			"Total receive frame errors on the router radio",
+			bandLabels, nil,
+		),
+		txBytesTotal: prometheus.NewDesc(
+			"starlink_router_radio_tx_bytes_total",
+			"Total bytes transmitted by the router radio",
+			bandLabels, nil,
+		),
+		txPacketsTotal: prometheus.NewDesc(
+			"starlink_router_radio_tx_packets_total",
+			"Total packets transmitted by the router radio",
+			bandLabels, nil,
+		),
+
+		// Gauges
+		thermalLevel: prometheus.NewDesc(
+			"starlink_router_radio_thermal_level",
+			"Thermal throttling level of the router radio (0 = not throttled)",
+			bandLabels, nil,
+		),
+		temperature: prometheus.NewDesc(
+			"starlink_router_radio_temperature_celsius",
+			"Router radio temperature in degrees Celsius",
+			bandLabels, nil,
+		),
+		powerReduction: prometheus.NewDesc(
+			"starlink_router_radio_power_reduction",
+			"Transmit power reduction applied by thermal throttling",
+			bandLabels, nil,
+		),
+		dutyCycle: prometheus.NewDesc(
+			"starlink_router_radio_duty_cycle",
+			"Transmit duty cycle applied by thermal throttling",
+			bandLabels, nil,
+		),
+		antennaRssi: prometheus.NewDesc(
+			"starlink_router_radio_antenna_rssi",
+			"Received signal strength per antenna in dBm",
+			[]string{"band", "antenna"}, nil,
+		),
+
+		// Status
+		up: prometheus.NewDesc(
+			"starlink_router_up",
+			"Whether the last scrape of Starlink router metrics was successful (1 = success, 0 = failure)",
+			nil, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *RouterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pingLatencySecondsSum
+	ch <- c.pingLatencySecondsCount
+	ch <- c.pingDropTotal
+	ch <- c.dnsResolverDropTotal
+	ch <- c.rxBytesTotal
+	ch <- c.rxPacketsTotal
+	ch <- c.rxFrameErrorsTotal
+	ch <- c.txBytesTotal
+	ch <- c.txPacketsTotal
+	ch <- c.thermalLevel
+	ch <- c.temperature
+	ch <- c.powerReduction
+	ch <- c.dutyCycle
+	ch <- c.antennaRssi
+	ch <- c.up
+}
+
+// Collect implements prometheus.Collector
+func (c *RouterCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Update(ch) // Failures are reported through starlink_router_up
+}
+
+// Update sends the router metrics to ch and returns the error that failed the scrape, if any
+func (c *RouterCollector) Update(ch chan<- prometheus.Metric) error {
+	// Counters from background tracker are emitted even if the router is unreachable
+	pingLatencySum, pingSampleCount, pingDrops := c.historyTracker.GetPingMetrics()
+	ch <- prometheus.MustNewConstMetric(c.pingLatencySecondsSum, prometheus.CounterValue, pingLatencySum)
+	ch <- prometheus.MustNewConstMetric(c.pingLatencySecondsCount, prometheus.CounterValue, pingSampleCount)
+	ch <- prometheus.MustNewConstMetric(c.pingDropTotal, prometheus.CounterValue, pingDrops)
+
+	for resolver, drops := range c.historyTracker.GetDNSResolverDrops() {
+		ch <- prometheus.MustNewConstMetric(c.dnsResolverDropTotal, prometheus.CounterValue, drops, resolver)
+	}
+
+	stats, err := c.client.GetRadioStats()
+	if err != nil {
+		c.logger.Error("Failed to get radio stats", "error", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0.0)
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1.0)
+
+	for _, radio := range stats.RadioStats {
+		// RX/TX counters
+		ch <- prometheus.MustNewConstMetric(c.rxBytesTotal, prometheus.CounterValue, float64(radio.RxStats.Bytes), radio.Band)
+		ch <- prometheus.MustNewConstMetric(c.rxPacketsTotal, prometheus.CounterValue, float64(radio.RxStats.Packets), radio.Band)
+		ch <- prometheus.MustNewConstMetric(c.rxFrameErrorsTotal, prometheus.CounterValue, float64(radio.RxStats.FrameErrors), radio.Band)
+		ch <- prometheus.MustNewConstMetric(c.txBytesTotal, prometheus.CounterValue, float64(radio.TxStats.Bytes), radio.Band)
+		ch <- prometheus.MustNewConstMetric(c.txPacketsTotal, prometheus.CounterValue, float64(radio.TxStats.Packets), radio.Band)
+
+		// Thermal status
+		ch <- prometheus.MustNewConstMetric(c.thermalLevel, prometheus.GaugeValue, float64(radio.ThermalStatus.Level), radio.Band)
+		ch <- prometheus.MustNewConstMetric(c.temperature, prometheus.GaugeValue, radio.ThermalStatus.TempC, radio.Band)
+		ch <- prometheus.MustNewConstMetric(c.powerReduction, prometheus.GaugeValue, float64(radio.ThermalStatus.PowerReduction), radio.Band)
+		ch <- prometheus.MustNewConstMetric(c.dutyCycle, prometheus.GaugeValue, float64(radio.ThermalStatus.DutyCycle), radio.Band)
+
+		// Antenna RSSI (antennas are numbered from 1)
+		for i, rssi := range radio.AntennaStatus.Rssi {
+			if rssi == 0 {
+				// Unpopulated antenna slot
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(c.antennaRssi, prometheus.GaugeValue, rssi, radio.Band, strconv.Itoa(i+1))
+		}
+	}
+
+	c.logger.Debug("Router scrape completed", "radios", len(stats.RadioStats))
+	return nil
+}
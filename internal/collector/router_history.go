@@ -0,0 +1,243 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+// dnsBucketSeconds is the width of each sample in the router's DNS resolver history
+const dnsBucketSeconds = 15.0
+
+// RouterHistoryTracker tracks cumulative ping and DNS metrics from router history
+// with a background ticker. It uses the same circular buffer integration as
+// BandwidthTracker, so restarts and polling gaps are handled identically.
+type RouterHistoryTracker struct {
+	historyCursor                        // Position in the 1-second ping ring buffer
+	dnsCursor              historyCursor // Position in the 15-second DNS ring buffer
+	mu                     sync.RWMutex
+	client                 client.RouterClient
+	logger                 *slog.Logger
+	pingLatencySecondsSum  float64            // Sum of ping latencies in seconds (summary metric)
+	pingLatencySampleCount float64            // Count of ping samples (summary metric)
+	pingDropCount          float64            // Count of ping drops
+	dnsDropCount           map[string]float64 // Count of failed 1-second DNS probes per resolver
+	lastError              error              // Last error encountered
+	interval               time.Duration
+	backoff                backoff // Only touched by the Start goroutine
+	stopCh                 chan struct{}
+	stoppedCh              chan struct{}
+	stopOnce               sync.Once
+}
+
+// NewRouterHistoryTracker creates a new router history tracker
+func NewRouterHistoryTracker(client client.RouterClient, logger *slog.Logger) *RouterHistoryTracker {
+	return &RouterHistoryTracker{
+		client:       client,
+		logger:       logger.With("tracker", "router_history"),
+		dnsDropCount: make(map[string]float64),
+		interval:     1 * time.Second,
+		stopCh:       make(chan struct{}),
+		stoppedCh:    make(chan struct{}),
+	}
+}
+
+// SetInterval changes how often router history is polled (must be called before Start)
+func (rt *RouterHistoryTracker) SetInterval(interval time.Duration) {
+	rt.interval = interval
+}
+
+// InheritState copies the cumulative counters from a previous tracker so they stay
+// monotonic across config reloads. The ring buffer cursors are only carried over
+// when both trackers poll the same router. prev must be stopped first.
+func (rt *RouterHistoryTracker) InheritState(prev *RouterHistoryTracker, keepCursor bool) {
+	prev.mu.RLock()
+	defer prev.mu.RUnlock()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.pingLatencySecondsSum = prev.pingLatencySecondsSum
+	rt.pingLatencySampleCount = prev.pingLatencySampleCount
+	rt.pingDropCount = prev.pingDropCount
+	for resolver, count := range prev.dnsDropCount {
+		rt.dnsDropCount[resolver] = count
+	}
+	if keepCursor {
+		rt.historyCursor = prev.historyCursor
+		rt.dnsCursor = prev.dnsCursor
+	}
+}
+
+// Start begins the background ticker that updates router counters every interval
+func (rt *RouterHistoryTracker) Start(ctx context.Context) {
+	ticker := time.NewTicker(rt.interval)
+	defer ticker.Stop()
+	defer close(rt.stoppedCh)
+
+	rt.backoff.base = rt.interval
+	rt.logger.Info("Router history tracker started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			rt.logger.Info("Router history tracker stopping")
+			return
+		case <-rt.stopCh:
+			rt.logger.Info("Router history tracker stopping")
+			return
+		case tick := <-ticker.C:
+			if !rt.backoff.ready(tick) {
+				// Router unreachable; wait out the backoff
+				continue
+			}
+			rt.update()
+		}
+	}
+}
+
+// Stop stops the router history tracker (safe to call multiple times)
+func (rt *RouterHistoryTracker) Stop() {
+	rt.stopOnce.Do(func() {
+		close(rt.stopCh)
+	})
+	<-rt.stoppedCh
+}
+
+// update fetches router history and updates counters (called on every tick outside backoff)
+func (rt *RouterHistoryTracker) update() {
+	history, err := rt.client.GetWifiHistory()
+	if err != nil {
+		rt.mu.Lock()
+		rt.lastError = err
+		rt.mu.Unlock()
+
+		delay := rt.backoff.fail(time.Now())
+		if rt.backoff.failures == 1 {
+			rt.logger.Warn("Failed to get router history, backing off", "error", err, "retry_in", delay)
+		} else {
+			rt.logger.Debug("Failed to get router history", "error", err, "failures", rt.backoff.failures, "retry_in", delay)
+		}
+		return
+	}
+	if failures := rt.backoff.succeed(); failures > 0 {
+		rt.logger.Info("Router history fetch recovered", "failures", failures)
+	}
+
+	rt.processHistory(history)
+}
+
+// processHistory processes new router history data and updates counters
+func (rt *RouterHistoryTracker) processHistory(history *client.WifiHistoryResponse) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	// Clear error on successful fetch
+	rt.lastError = nil
+
+	rt.processPing(history)
+	rt.processDNS(history)
+}
+
+// processPing integrates the 1-second ping latency and drop rate buffers
+func (rt *RouterHistoryTracker) processPing(history *client.WifiHistoryResponse) {
+	arrayLen := len(history.PingLatencyMs)
+	if arrayLen == 0 {
+		rt.logger.Warn("Empty router ping history arrays")
+		return
+	}
+
+	if len(history.PingDropRate) != arrayLen {
+		rt.logger.Error("Array length mismatch",
+			"ping_latency", arrayLen,
+			"ping_drop", len(history.PingDropRate))
+		return
+	}
+
+	indices := rt.newSamples(history.Current, arrayLen, rt.logger)
+	if len(indices) == 0 {
+		return
+	}
+
+	var pingLatencyDelta, pingDropDelta float64
+	for _, idx := range indices {
+		pingLatencyDelta += history.PingLatencyMs[idx] / 1000.0 // ms to seconds
+		pingDropDelta += history.PingDropRate[idx]
+	}
+
+	rt.pingLatencySecondsSum += pingLatencyDelta
+	rt.pingLatencySampleCount += float64(len(indices)) // Each sample counted
+	rt.pingDropCount += pingDropDelta
+
+	rt.logger.Debug("Router ping update",
+		"time_delta", len(indices),
+		"ping_latency_delta_seconds", pingLatencyDelta,
+		"ping_drop_delta", pingDropDelta)
+}
+
+// processDNS integrates the 15-second DNS resolver drop rate buffers. Each bucket
+// holds the fraction of the router's 1-second resolver probes that failed, so
+// rate * 15 gives the number of failed probes in that bucket.
+func (rt *RouterHistoryTracker) processDNS(history *client.WifiHistoryResponse) {
+	// All resolvers share the same ring buffer position, so use the first
+	// non-empty buffer to size it
+	arrayLen := 0
+	for _, rates := range history.DnsResolverDropRate {
+		if len(rates) > 0 {
+			arrayLen = len(rates)
+			break
+		}
+	}
+	if arrayLen == 0 {
+		return
+	}
+
+	indices := rt.dnsCursor.newSamples(history.CurrentIndex15s, arrayLen, rt.logger)
+	if len(indices) == 0 {
+		return
+	}
+
+	for resolver, rates := range history.DnsResolverDropRate {
+		if len(rates) != arrayLen {
+			rt.logger.Error("DNS resolver array length mismatch",
+				"resolver", resolver,
+				"expected", arrayLen,
+				"actual", len(rates))
+			continue
+		}
+
+		var dropDelta float64
+		for _, idx := range indices {
+			dropDelta += rates[idx] * dnsBucketSeconds
+		}
+		rt.dnsDropCount[resolver] += dropDelta
+	}
+}
+
+// GetPingMetrics returns router ping summary metrics: latency sum (seconds), sample count, drop count
+func (rt *RouterHistoryTracker) GetPingMetrics() (latencySum, sampleCount, dropCount float64) {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.pingLatencySecondsSum, rt.pingLatencySampleCount, rt.pingDropCount
+}
+
+// GetDNSResolverDrops returns a copy of the cumulative failed DNS probe count per resolver
+func (rt *RouterHistoryTracker) GetDNSResolverDrops() map[string]float64 {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	drops := make(map[string]float64, len(rt.dnsDropCount))
+	for resolver, count := range rt.dnsDropCount {
+		drops[resolver] = count
+	}
+	return drops
+}
+
+// GetLastError returns the last error encountered (or nil if no error)
+func (rt *RouterHistoryTracker) GetLastError() error {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.lastError
+}
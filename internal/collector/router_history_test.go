@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+func newWifiHistory(current, current15s uint64) *client.WifiHistoryResponse {
+	return &client.WifiHistoryResponse{
+		Current:         current,
+		PingDropRate:    make([]float64, 900),
+		PingLatencyMs:   make([]float64, 900),
+		CurrentIndex15s: current15s,
+		DnsResolverDropRate: map[string][]float64{
+			"8.8.8.8": make([]float64, 60),
+		},
+	}
+}
+
+func TestRouterHistoryTracker_PingUpdate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewRouterHistoryTracker(nil, logger)
+
+	tracker.processHistory(newWifiHistory(1000, 100))
+
+	// 2 seconds later: new samples at 1000%900=100 and 1001%900=101
+	history := newWifiHistory(1002, 100)
+	history.PingLatencyMs[100] = 20
+	history.PingLatencyMs[101] = 30
+	history.PingDropRate[101] = 0.5
+	tracker.processHistory(history)
+
+	latencySum, sampleCount, dropCount := tracker.GetPingMetrics()
+	if latencySum != 0.05 {
+		t.Errorf("Expected latency sum 0.05, got %f", latencySum)
+	}
+	if sampleCount != 2 {
+		t.Errorf("Expected 2 samples, got %f", sampleCount)
+	}
+	if dropCount != 0.5 {
+		t.Errorf("Expected 0.5 drops, got %f", dropCount)
+	}
+}
+
+func TestRouterHistoryTracker_DNSDrops(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewRouterHistoryTracker(nil, logger)
+
+	tracker.processHistory(newWifiHistory(1000, 100))
+
+	// One new 15s bucket at 100%60=40 with a 20% drop rate = 3 failed probes
+	history := newWifiHistory(1015, 101)
+	history.DnsResolverDropRate["8.8.8.8"][40] = 0.2
+	tracker.processHistory(history)
+
+	drops := tracker.GetDNSResolverDrops()
+	if drops["8.8.8.8"] != 3 {
+		t.Errorf("Expected 3 DNS drops, got %f", drops["8.8.8.8"])
+	}
+}
+
+func TestRouterHistoryTracker_CounterReset(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	tracker := NewRouterHistoryTracker(nil, logger)
+
+	tracker.processHistory(newWifiHistory(1000, 100))
+
+	// Router restart - both counters go backwards
+	history := newWifiHistory(10, 1)
+	for i := range history.PingLatencyMs {
+		history.PingLatencyMs[i] = 20
+	}
+	tracker.processHistory(history)
+
+	_, sampleCount, _ := tracker.GetPingMetrics()
+	if sampleCount != 0 {
+		t.Errorf("Expected no samples after reset, got %f", sampleCount)
+	}
+	if tracker.lastCurrent != 10 {
+		t.Errorf("Expected lastCurrent=10 after reset, got %d", tracker.lastCurrent)
+	}
+	if tracker.dnsCursor.lastCurrent != 1 {
+		t.Errorf("Expected DNS lastCurrent=1 after reset, got %d", tracker.dnsCursor.lastCurrent)
+	}
+}
+
+func TestRouterHistoryTracker_InheritState(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	prev := NewRouterHistoryTracker(nil, logger)
+	prev.processHistory(newWifiHistory(1000, 100))
+	history := newWifiHistory(1015, 101)
+	history.PingLatencyMs[100] = 20
+	history.DnsResolverDropRate["8.8.8.8"][40] = 0.2
+	prev.processHistory(history)
+
+	// New router address: counters carry over, cursor re-initializes
+	tracker := NewRouterHistoryTracker(nil, logger)
+	tracker.InheritState(prev, false)
+
+	_, sampleCount, _ := tracker.GetPingMetrics()
+	if sampleCount != 15 {
+		t.Errorf("Expected 15 inherited samples, got %f", sampleCount)
+	}
+	if drops := tracker.GetDNSResolverDrops(); drops["8.8.8.8"] != 3 {
+		t.Errorf("Expected 3 inherited DNS drops, got %f", drops["8.8.8.8"])
+	}
+	if tracker.initialized {
+		t.Error("Expected cursor not to be inherited")
+	}
+
+	// Same router address: cursor carries over so no samples are skipped
+	tracker = NewRouterHistoryTracker(nil, logger)
+	tracker.InheritState(prev, true)
+	if !tracker.initialized || tracker.lastCurrent != 1015 || tracker.dnsCursor.lastCurrent != 101 {
+		t.Errorf("Expected inherited cursors at 1015/101, got %d/%d", tracker.lastCurrent, tracker.dnsCursor.lastCurrent)
+	}
+}
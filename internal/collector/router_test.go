@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeRouterClient struct {
+	stats   *client.RadioStatsResponse
+	history *client.WifiHistoryResponse
+	err     error
+}
+
+func (f *fakeRouterClient) GetRadioStats() (*client.RadioStatsResponse, error) {
+	return f.stats, f.err
+}
+
+func (f *fakeRouterClient) GetWifiHistory() (*client.WifiHistoryResponse, error) {
+	return f.history, f.err
+}
+
+func TestRouterCollector_RadioStats(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeRouterClient{
+		stats: &client.RadioStatsResponse{
+			RadioStats: []client.RadioStats{
+				{
+					Band:          "2.4ghz",
+					RxStats:       client.RadioRxStats{Bytes: 1000, Packets: 10, FrameErrors: 1},
+					TxStats:       client.RadioTxStats{Bytes: 2000, Packets: 20},
+					ThermalStatus: client.RadioThermalStatus{Level: 0, TempC: 55.5},
+					AntennaStatus: client.RadioAntennaStatus{Rssi: []float64{-40, -42, 0, 0}},
+				},
+				{
+					Band:    "5ghz",
+					RxStats: client.RadioRxStats{Bytes: 3000},
+					TxStats: client.RadioTxStats{Bytes: 4000},
+				},
+			},
+		},
+	}
+	collector := NewRouterCollector(fake, NewRouterHistoryTracker(fake, logger), logger)
+
+	expected := `
+# HELP starlink_router_radio_rx_bytes_total Total bytes received by the router radio
+# TYPE starlink_router_radio_rx_bytes_total counter
+starlink_router_radio_rx_bytes_total{band="2.4ghz"} 1000
+starlink_router_radio_rx_bytes_total{band="5ghz"} 3000
+# HELP starlink_router_radio_antenna_rssi Received signal strength per antenna in dBm
+# TYPE starlink_router_radio_antenna_rssi gauge
+starlink_router_radio_antenna_rssi{antenna="1",band="2.4ghz"} -40
+starlink_router_radio_antenna_rssi{antenna="2",band="2.4ghz"} -42
+# HELP starlink_router_up Whether the last scrape of Starlink router metrics was successful (1 = success, 0 = failure)
+# TYPE starlink_router_up gauge
+starlink_router_up 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"starlink_router_radio_rx_bytes_total",
+		"starlink_router_radio_antenna_rssi",
+		"starlink_router_up")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRouterCollector_Error(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeRouterClient{err: errors.New("unreachable")}
+	collector := NewRouterCollector(fake, NewRouterHistoryTracker(fake, logger), logger)
+
+	expected := `
+# HELP starlink_router_ping_drop_total Total router ping drops
+# TYPE starlink_router_ping_drop_total counter
+starlink_router_ping_drop_total 0
+# HELP starlink_router_up Whether the last scrape of Starlink router metrics was successful (1 = success, 0 = failure)
+# TYPE starlink_router_up gauge
+starlink_router_up 0
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"starlink_router_ping_drop_total",
+		"starlink_router_up")
+	if err != nil {
+		t.Error(err)
+	}
+}
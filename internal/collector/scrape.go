@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Updater is a prometheus.Collector that also reports whether collection failed
+type Updater interface {
+	prometheus.Collector
+	Update(ch chan<- prometheus.Metric) error
+}
+
+// ScrapeCollector runs named groups of collectors concurrently and reports each
+// group's success and duration, node_exporter style. A scrape can be limited to
+// some groups with Filter.
+type ScrapeCollector struct {
+	groups map[string][]prometheus.Collector
+	logger *slog.Logger
+
+	success  *prometheus.Desc
+	duration *prometheus.Desc
+}
+
+// NewScrapeCollector creates an empty scrape collector
+func NewScrapeCollector(logger *slog.Logger) *ScrapeCollector {
+	return &ScrapeCollector{
+		groups: make(map[string][]prometheus.Collector),
+		logger: logger,
+
+		success: prometheus.NewDesc(
+			"starlink_scrape_collector_success",
+			"Whether a collector succeeded (1 = success, 0 = failure)",
+			[]string{"collector"}, nil,
+		),
+		duration: prometheus.NewDesc(
+			"starlink_scrape_collector_duration_seconds",
+			"Duration of a collector scrape in seconds",
+			[]string{"collector"}, nil,
+		),
+	}
+}
+
+// Add adds collectors to the named group. Collectors implementing Updater fail
+// the group when Update returns an error.
+func (sc *ScrapeCollector) Add(name string, collectors ...prometheus.Collector) {
+	sc.groups[name] = append(sc.groups[name], collectors...)
+}
+
+// Names returns the group names in sorted order
+func (sc *ScrapeCollector) Names() []string {
+	names := make([]string, 0, len(sc.groups))
+	for name := range sc.groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Filter returns a scrape collector limited to the named groups. It fails if a
+// name is unknown or its collector is disabled.
+func (sc *ScrapeCollector) Filter(names []string) (*ScrapeCollector, error) {
+	filtered := &ScrapeCollector{
+		groups:   make(map[string][]prometheus.Collector, len(names)),
+		logger:   sc.logger,
+		success:  sc.success,
+		duration: sc.duration,
+	}
+	for _, name := range names {
+		group, ok := sc.groups[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or disabled collector %q (enabled: %v)", name, sc.Names())
+		}
+		filtered.groups[name] = group
+	}
+	return filtered, nil
+}
+
+// Describe implements prometheus.Collector
+func (sc *ScrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.success
+	ch <- sc.duration
+	for _, group := range sc.groups {
+		for _, c := range group {
+			c.Describe(ch)
+		}
+	}
+}
+
+// Collect implements prometheus.Collector
+func (sc *ScrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	var wg sync.WaitGroup
+	for name, group := range sc.groups {
+		wg.Go(func() {
+			sc.collectGroup(name, group, ch)
+		})
+	}
+	wg.Wait()
+}
+
+// collectGroup collects one group and reports its success and duration
+func (sc *ScrapeCollector) collectGroup(name string, group []prometheus.Collector, ch chan<- prometheus.Metric) {
+	start := time.Now()
+	success := 1.0
+	for _, c := range group {
+		updater, ok := c.(Updater)
+		if !ok {
+			c.Collect(ch)
+			continue
+		}
+		if err := updater.Update(ch); err != nil {
+			sc.logger.Debug("Collector failed", "collector", name, "error", err)
+			success = 0
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(sc.duration, prometheus.GaugeValue, time.Since(start).Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(sc.success, prometheus.GaugeValue, success, name)
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func newTestScrapeCollector(logger *slog.Logger) *ScrapeCollector {
+	routerClient := &fakeRouterClient{err: errors.New("router unreachable")}
+	meshClient := &fakeMeshClient{status: &client.MeshStatusResponse{}, backhaulErr: errors.New("not a repeater")}
+
+	sc := NewScrapeCollector(logger)
+	sc.Add("router", NewRouterCollector(routerClient, NewRouterHistoryTracker(routerClient, logger), logger))
+	sc.Add("mesh", NewMeshCollector(meshClient, logger))
+	return sc
+}
+
+func TestScrapeCollector_Success(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	sc := newTestScrapeCollector(logger)
+
+	expected := `
+# HELP starlink_scrape_collector_success Whether a collector succeeded (1 = success, 0 = failure)
+# TYPE starlink_scrape_collector_success gauge
+starlink_scrape_collector_success{collector="mesh"} 1
+starlink_scrape_collector_success{collector="router"} 0
+`
+	if err := testutil.CollectAndCompare(sc, strings.NewReader(expected), "starlink_scrape_collector_success"); err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(sc, "starlink_scrape_collector_duration_seconds"); n != 2 {
+		t.Errorf("Expected a duration per collector, got %d", n)
+	}
+}
+
+func TestScrapeCollector_Filter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	sc := newTestScrapeCollector(logger)
+
+	filtered, err := sc.Filter([]string{"mesh"})
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	if n := testutil.CollectAndCount(filtered, "starlink_router_up"); n != 0 {
+		t.Errorf("Expected router collector to be skipped, got %d metrics", n)
+	}
+	if n := testutil.CollectAndCount(filtered, "starlink_mesh_up"); n != 1 {
+		t.Errorf("Expected mesh collector to run, got %d metrics", n)
+	}
+
+	if _, err := sc.Filter([]string{"transceiver"}); err == nil {
+		t.Error("Expected error for unknown collector")
+	}
+}
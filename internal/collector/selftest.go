@@ -0,0 +1,306 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrSelfTestRunning is returned when a self-test is requested while one is in progress
+var ErrSelfTestRunning = errors.New("self-test already running")
+
+// SelfTestResult contains the outcome of one self-test run
+type SelfTestResult struct {
+	Time             time.Time `json:"time"`
+	Duration         float64   `json:"durationSeconds"`
+	Error            string    `json:"error,omitempty"`
+	Passed           bool      `json:"passed"`
+	Report           string    `json:"report,omitempty"`
+	HardwareSelfTest string    `json:"hardwareSelfTest,omitempty"`
+	FailureCodes     []string  `json:"failureCodes,omitempty"`
+}
+
+// SelfTestRunner runs the on-dish self-test on a schedule and on demand, and
+// exports the most recent result. Scrapes never trigger a self-test.
+type SelfTestRunner struct {
+	mu         sync.RWMutex
+	client     client.SelfTestClient
+	logger     *slog.Logger
+	interval   time.Duration
+	running    atomic.Bool
+	lastResult *SelfTestResult
+	stopCh     chan struct{}
+	stoppedCh  chan struct{}
+	stopOnce   sync.Once
+
+	passed            *prometheus.Desc
+	hardwarePassed    *prometheus.Desc
+	failureCode       *prometheus.Desc
+	lastRunTimestamp  *prometheus.Desc
+	lastRunDuration   *prometheus.Desc
+	lastRunSuccessful *prometheus.Desc
+}
+
+// NewSelfTestRunner creates a new self-test runner. An interval of zero disables
+// scheduled runs; on-demand runs are always available.
+func NewSelfTestRunner(c client.SelfTestClient, interval time.Duration, logger *slog.Logger) *SelfTestRunner {
+	return &SelfTestRunner{
+		client:    c,
+		logger:    logger,
+		interval:  interval,
+		stopCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+
+		passed: prometheus.NewDesc(
+			"starlink_self_test_passed",
+			"Whether the last on-dish self-test passed (1 = passed, 0 = failed)",
+			nil, nil,
+		),
+		hardwarePassed: prometheus.NewDesc(
+			"starlink_self_test_hardware_passed",
+			"Whether the hardware self-test reported in diagnostics passed (1 = passed, 0 = failed or no result)",
+			[]string{"result"}, nil,
+		),
+		failureCode: prometheus.NewDesc(
+			"starlink_self_test_failure_code",
+			"Hardware self-test codes reported as failing by the last self-test",
+			[]string{"code"}, nil,
+		),
+		lastRunTimestamp: prometheus.NewDesc(
+			"starlink_self_test_last_run_timestamp_seconds",
+			"Unix timestamp of the last self-test run",
+			nil, nil,
+		),
+		lastRunDuration: prometheus.NewDesc(
+			"starlink_self_test_last_run_duration_seconds",
+			"Duration of the last self-test run in seconds",
+			nil, nil,
+		),
+		lastRunSuccessful: prometheus.NewDesc(
+			"starlink_self_test_last_run_successful",
+			"Whether the last self-test run completed without an RPC error (1 = success, 0 = failure)",
+			nil, nil,
+		),
+	}
+}
+
+// InheritState copies the last result from a previous runner so a config reload
+// neither loses the exported result nor triggers an early self-test
+func (r *SelfTestRunner) InheritState(prev *SelfTestRunner) {
+	last := prev.LastResult()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastResult = last
+}
+
+// Start runs a self-test once an interval has passed since the inherited result,
+// or since startup if there is none, and then on every interval until stopped.
+// Restarts, e.g. a crash loop, don't each run a self-test.
+func (r *SelfTestRunner) Start(ctx context.Context) {
+	defer close(r.stoppedCh)
+
+	if r.interval <= 0 {
+		r.logger.Info("Scheduled self-tests disabled")
+		select {
+		case <-ctx.Done():
+		case <-r.stopCh:
+		}
+		return
+	}
+
+	r.logger.Info("Self-test runner started", "interval", r.interval)
+
+	// Wait out the interval, counting from the inherited result if there is one
+	delay := r.interval
+	if last := r.LastResult(); last != nil {
+		delay = max(r.interval-time.Since(last.Time), 0)
+	}
+	first := time.NewTimer(delay)
+	defer first.Stop()
+
+	select {
+	case <-ctx.Done():
+		r.logger.Info("Self-test runner stopping")
+		return
+	case <-r.stopCh:
+		r.logger.Info("Self-test runner stopping")
+		return
+	case <-first.C:
+		r.runScheduled()
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Self-test runner stopping")
+			return
+		case <-r.stopCh:
+			r.logger.Info("Self-test runner stopping")
+			return
+		case <-ticker.C:
+			r.runScheduled()
+		}
+	}
+}
+
+// Stop stops the self-test runner (safe to call multiple times)
+func (r *SelfTestRunner) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+	})
+	<-r.stoppedCh
+}
+
+// runScheduled runs a scheduled self-test, skipping it if an on-demand run is in progress
+func (r *SelfTestRunner) runScheduled() {
+	if _, err := r.Run(); err != nil && !errors.Is(err, ErrSelfTestRunning) {
+		r.logger.Warn("Scheduled self-test failed", "error", err)
+	}
+}
+
+// Run executes a self-test and records its result. It returns ErrSelfTestRunning
+// if another run is in progress.
+func (r *SelfTestRunner) Run() (*SelfTestResult, error) {
+	if !r.running.CompareAndSwap(false, true) {
+		return nil, ErrSelfTestRunning
+	}
+	defer r.running.Store(false)
+
+	start := time.Now()
+	result := &SelfTestResult{Time: start}
+
+	selfTest, err := r.client.RunSelfTest()
+	if err == nil {
+		result.Passed = selfTest.Passed
+		result.Report = selfTest.Report
+
+		// The self-test result codes are reported through diagnostics
+		var diagnostics *client.DiagnosticsResponse
+		diagnostics, err = r.client.GetDiagnostics()
+		if err == nil {
+			result.HardwareSelfTest = diagnostics.HardwareSelfTest
+			result.FailureCodes = diagnostics.HardwareSelfTestCodes
+		}
+	}
+	result.Duration = time.Since(start).Seconds()
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.lastResult = result
+	r.mu.Unlock()
+
+	if err != nil {
+		return result, err
+	}
+
+	r.logger.Info("Self-test completed",
+		"passed", result.Passed,
+		"hardware_self_test", result.HardwareSelfTest,
+		"failure_codes", result.FailureCodes,
+		"duration_seconds", result.Duration)
+	return result, nil
+}
+
+// LastResult returns the most recent self-test result, or nil if none has run
+func (r *SelfTestRunner) LastResult() *SelfTestResult {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastResult
+}
+
+// ServeHTTP returns the last self-test result on GET and runs a self-test on POST
+func (r *SelfTestRunner) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var result *SelfTestResult
+	status := http.StatusOK
+
+	switch req.Method {
+	case http.MethodGet:
+		result = r.LastResult()
+		if result == nil {
+			http.Error(w, "no self-test has run yet", http.StatusNotFound)
+			return
+		}
+	case http.MethodPost:
+		var err error
+		result, err = r.Run()
+		if errors.Is(err, ErrSelfTestRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			status = http.StatusBadGateway
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		r.logger.Debug("Failed to write self-test response", "error", err)
+	}
+}
+
+// Describe implements prometheus.Collector
+func (r *SelfTestRunner) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.passed
+	ch <- r.hardwarePassed
+	ch <- r.failureCode
+	ch <- r.lastRunTimestamp
+	ch <- r.lastRunDuration
+	ch <- r.lastRunSuccessful
+}
+
+// Collect implements prometheus.Collector
+func (r *SelfTestRunner) Collect(ch chan<- prometheus.Metric) {
+	result := r.LastResult()
+	if result == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(r.lastRunTimestamp, prometheus.GaugeValue, float64(result.Time.Unix()))
+	ch <- prometheus.MustNewConstMetric(r.lastRunDuration, prometheus.GaugeValue, result.Duration)
+
+	if result.Error != "" {
+		ch <- prometheus.MustNewConstMetric(r.lastRunSuccessful, prometheus.GaugeValue, 0.0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(r.lastRunSuccessful, prometheus.GaugeValue, 1.0)
+
+	passed := 0.0
+	if result.Passed {
+		passed = 1.0
+	}
+	ch <- prometheus.MustNewConstMetric(r.passed, prometheus.GaugeValue, passed)
+
+	hardwarePassed := 0.0
+	if result.HardwareSelfTest == "passed" {
+		hardwarePassed = 1.0
+	}
+	ch <- prometheus.MustNewConstMetric(r.hardwarePassed, prometheus.GaugeValue, hardwarePassed, result.HardwareSelfTest)
+
+	seen := make(map[string]bool, len(result.FailureCodes))
+	for _, code := range result.FailureCodes {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		ch <- prometheus.MustNewConstMetric(r.failureCode, prometheus.GaugeValue, 1.0, code)
+	}
+}
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeSelfTestClient struct {
+	selfTest    *client.SelfTestResponse
+	diagnostics *client.DiagnosticsResponse
+	err         error
+	runs        atomic.Int32
+}
+
+func (f *fakeSelfTestClient) RunSelfTest() (*client.SelfTestResponse, error) {
+	f.runs.Add(1)
+	return f.selfTest, f.err
+}
+
+func (f *fakeSelfTestClient) GetDiagnostics() (*client.DiagnosticsResponse, error) {
+	return f.diagnostics, f.err
+}
+
+func TestSelfTestRunner_FailingCodes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeSelfTestClient{
+		selfTest: &client.SelfTestResponse{Passed: false, Report: "gps failed"},
+		diagnostics: &client.DiagnosticsResponse{
+			HardwareSelfTest:      "failed",
+			HardwareSelfTestCodes: []string{"gps", "temperature"},
+		},
+	}
+	runner := NewSelfTestRunner(fake, 0, logger)
+
+	if _, err := runner.Run(); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	expected := `
+# HELP starlink_self_test_passed Whether the last on-dish self-test passed (1 = passed, 0 = failed)
+# TYPE starlink_self_test_passed gauge
+starlink_self_test_passed 0
+# HELP starlink_self_test_failure_code Hardware self-test codes reported as failing by the last self-test
+# TYPE starlink_self_test_failure_code gauge
+starlink_self_test_failure_code{code="gps"} 1
+starlink_self_test_failure_code{code="temperature"} 1
+# HELP starlink_self_test_last_run_successful Whether the last self-test run completed without an RPC error (1 = success, 0 = failure)
+# TYPE starlink_self_test_last_run_successful gauge
+starlink_self_test_last_run_successful 1
+`
+	err := testutil.CollectAndCompare(runner, strings.NewReader(expected),
+		"starlink_self_test_passed",
+		"starlink_self_test_failure_code",
+		"starlink_self_test_last_run_successful")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSelfTestRunner_NoResultBeforeRun(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	runner := NewSelfTestRunner(&fakeSelfTestClient{}, 0, logger)
+
+	if count := testutil.CollectAndCount(runner); count != 0 {
+		t.Errorf("Expected no metrics before first run, got %d", count)
+	}
+
+	rec := httptest.NewRecorder()
+	runner.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/selftest", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 before first run, got %d", rec.Code)
+	}
+}
+
+func TestSelfTestRunner_OnDemandError(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	runner := NewSelfTestRunner(&fakeSelfTestClient{err: errors.New("unreachable")}, 0, logger)
+
+	rec := httptest.NewRecorder()
+	runner.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/selftest", nil))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 on RPC failure, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "unreachable") {
+		t.Errorf("Expected error in response body, got %q", rec.Body.String())
+	}
+}
+
+func TestSelfTestRunner_WaitsAnIntervalAfterStart(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeSelfTestClient{
+		selfTest:    &client.SelfTestResponse{Passed: true},
+		diagnostics: &client.DiagnosticsResponse{},
+	}
+	runner := NewSelfTestRunner(fake, 300*time.Millisecond, logger)
+	go runner.Start(t.Context())
+	defer runner.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	if runs := fake.runs.Load(); runs != 0 {
+		t.Fatalf("Expected no self-test at startup, got %d", runs)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.runs.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runs := fake.runs.Load(); runs != 1 {
+		t.Errorf("Expected one scheduled self-test after the interval, got %d", runs)
+	}
+}
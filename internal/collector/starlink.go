@@ -171,6 +171,11 @@ func (c *StarlinkCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector
 func (c *StarlinkCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Update(ch) // Failures are reported through starlink_up
+}
+
+// Update sends the dish metrics to ch and returns the error that failed the scrape, if any
+func (c *StarlinkCollector) Update(ch chan<- prometheus.Metric) error {
 	c.logger.Debug("Prometheus scrape started")
 
 	// Get status
@@ -193,7 +198,7 @@ func (c *StarlinkCollector) Collect(ch chan<- prometheus.Metric) {
 		ch <- prometheus.MustNewConstMetric(c.pingDropTotal, prometheus.CounterValue, pingDrops)
 
 		c.logger.Debug("Prometheus scrape completed (error path)", "download_bytes", download, "upload_bytes", upload)
-		return
+		return err
 	}
 
 	// Emit up=1 for successful scrape
@@ -320,4 +325,5 @@ func (c *StarlinkCollector) Collect(ch chan<- prometheus.Metric) {
 	)
 
 	c.logger.Debug("Prometheus scrape completed", "download_bytes", download, "upload_bytes", upload)
+	return nil
 }
@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// StatusCache shares get_status responses between scrapes. A background poller
+// keeps the response fresh; callers reuse it while it is younger than maxAge and
+// concurrent callers that find it stale are coalesced into a single RPC.
+// History requests pass straight through, so it can stand in for a client.Client.
+type StatusCache struct {
+	mu      sync.RWMutex
+	client  client.Client
+	logger  *slog.Logger
+	maxAge  time.Duration
+	group   singleflight.Group
+	status  *client.StatusResponse // Last successful response (nil until the first)
+	fetched time.Time              // When status was fetched
+
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+	stopOnce  sync.Once
+
+	age *prometheus.Desc
+}
+
+// NewStatusCache creates a new status cache
+func NewStatusCache(c client.Client, maxAge time.Duration, logger *slog.Logger) *StatusCache {
+	return &StatusCache{
+		client:    c,
+		logger:    logger.With("component", "status_cache"),
+		maxAge:    maxAge,
+		stopCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+
+		age: prometheus.NewDesc(
+			"starlink_exporter_status_cache_age_seconds",
+			"Age of the cached get_status response in seconds",
+			nil, nil,
+		),
+	}
+}
+
+// Start begins the background poller that refreshes the status every maxAge
+func (sc *StatusCache) Start(ctx context.Context) {
+	ticker := time.NewTicker(sc.maxAge)
+	defer ticker.Stop()
+	defer close(sc.stoppedCh)
+
+	sc.logger.Info("Status poller started", "max_age", sc.maxAge)
+
+	for {
+		select {
+		case <-ctx.Done():
+			sc.logger.Info("Status poller stopping")
+			return
+		case <-sc.stopCh:
+			sc.logger.Info("Status poller stopping")
+			return
+		case <-ticker.C:
+			if _, err := sc.refresh(); err != nil {
+				// Scrapes retry and report the failure through starlink_up
+				sc.logger.Debug("Failed to refresh status", "error", err)
+			}
+		}
+	}
+}
+
+// Stop stops the status poller (safe to call multiple times)
+func (sc *StatusCache) Stop() {
+	sc.stopOnce.Do(func() {
+		close(sc.stopCh)
+	})
+	<-sc.stoppedCh
+}
+
+// GetStatus returns the cached status if it is younger than maxAge, otherwise
+// fetches a fresh one. Errors are never cached.
+func (sc *StatusCache) GetStatus() (*client.StatusResponse, error) {
+	if status := sc.fresh(); status != nil {
+		return status, nil
+	}
+	return sc.refresh()
+}
+
+// GetHistory passes through to the underlying client
+func (sc *StatusCache) GetHistory() (*client.HistoryResponse, error) {
+	return sc.client.GetHistory()
+}
+
+// fresh returns the cached status, or nil if there is none or it is too old
+func (sc *StatusCache) fresh() *client.StatusResponse {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if sc.status == nil || time.Since(sc.fetched) >= sc.maxAge {
+		return nil
+	}
+	return sc.status
+}
+
+// refresh fetches the status, sharing one in-flight RPC between concurrent callers
+func (sc *StatusCache) refresh() (*client.StatusResponse, error) {
+	v, err, _ := sc.group.Do("get_status", func() (any, error) {
+		status, err := sc.client.GetStatus()
+		if err != nil {
+			return nil, err
+		}
+
+		sc.mu.Lock()
+		sc.status = status
+		sc.fetched = time.Now()
+		sc.mu.Unlock()
+		return status, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*client.StatusResponse), nil
+}
+
+// Describe implements prometheus.Collector
+func (sc *StatusCache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.age
+}
+
+// Collect implements prometheus.Collector
+func (sc *StatusCache) Collect(ch chan<- prometheus.Metric) {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	if sc.status == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(sc.age, prometheus.GaugeValue, time.Since(sc.fetched).Seconds())
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+type fakeStatusClient struct {
+	calls atomic.Int32
+	delay time.Duration
+	err   error
+}
+
+func (f *fakeStatusClient) GetStatus() (*client.StatusResponse, error) {
+	f.calls.Add(1)
+	time.Sleep(f.delay)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &client.StatusResponse{}, nil
+}
+
+func (f *fakeStatusClient) GetHistory() (*client.HistoryResponse, error) {
+	return &client.HistoryResponse{}, nil
+}
+
+func TestStatusCache_ReusesFreshStatus(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeStatusClient{}
+	cache := NewStatusCache(fake, time.Minute, logger)
+
+	for range 3 {
+		if _, err := cache.GetStatus(); err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+	}
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 RPC within max age, got %d", calls)
+	}
+
+	// Expire the cached response
+	cache.fetched = time.Now().Add(-2 * time.Minute)
+	if _, err := cache.GetStatus(); err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("Expected a refresh after max age, got %d RPCs", calls)
+	}
+}
+
+func TestStatusCache_CoalescesConcurrentCallers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeStatusClient{delay: 50 * time.Millisecond}
+	cache := NewStatusCache(fake, time.Minute, logger)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			if _, err := cache.GetStatus(); err != nil {
+				t.Errorf("GetStatus failed: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("Expected concurrent callers to share 1 RPC, got %d", calls)
+	}
+}
+
+func TestStatusCache_ErrorsNotCached(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	fake := &fakeStatusClient{err: errors.New("unreachable")}
+	cache := NewStatusCache(fake, time.Minute, logger)
+
+	for range 2 {
+		if _, err := cache.GetStatus(); err == nil {
+			t.Error("Expected error from unreachable dish")
+		}
+	}
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("Expected every call to retry after an error, got %d RPCs", calls)
+	}
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatusFieldsCollector exports the fields of the dish's get_status response
+// through a FieldMapper, including ones StarlinkCollector has no metric for
+type StatusFieldsCollector struct {
+	client client.Client
+	mapper *FieldMapper
+	logger *slog.Logger
+}
+
+// NewStatusFieldsCollector creates a new status fields collector
+func NewStatusFieldsCollector(c client.Client, mapper *FieldMapper, logger *slog.Logger) *StatusFieldsCollector {
+	return &StatusFieldsCollector{
+		client: c,
+		mapper: mapper,
+		logger: logger.With("component", "status_fields"),
+	}
+}
+
+// Describe implements prometheus.Collector. It sends nothing, as the metrics
+// depend on the fields each response carries.
+func (c *StatusFieldsCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (c *StatusFieldsCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Update(ch) // Failures are reported through starlink_scrape_collector_success
+}
+
+// Update sends a gauge for every mapped get_status field to ch
+func (c *StatusFieldsCollector) Update(ch chan<- prometheus.Metric) error {
+	status, err := c.client.GetStatus()
+	if err != nil {
+		c.logger.Debug("Failed to get status", "error", err)
+		return err
+	}
+	if status.Raw == nil {
+		return errors.New("status response has no raw message")
+	}
+	c.mapper.Map(status.Raw.ProtoReflect(), ch)
+	return nil
+}
@@ -0,0 +1,508 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// labelNameRE matches valid Prometheus label names
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// metricNameRE matches valid Prometheus metric names
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Config is the full exporter configuration
+type Config struct {
+	LogLevel   string            `yaml:"log_level"`
+	Targets    Targets           `yaml:"targets"`
+	Collectors Collectors        `yaml:"collectors"`
+	Labels     map[string]string `yaml:"labels"`
+	HTTP       HTTP              `yaml:"http"`
+
+	RemoteWrite RemoteWrite `yaml:"remote_write"`
+	OTLP        OTLP        `yaml:"otlp"`
+	InfluxDB    InfluxDB    `yaml:"influxdb"`
+	MQTT        MQTT        `yaml:"mqtt"`
+}
+
+// Targets contains the gRPC addresses of the Starlink devices
+type Targets struct {
+	Dish   string `yaml:"dish"`
+	Router string `yaml:"router"`
+
+	// Stream multiplexes requests over one Device.Stream per target instead of a
+	// unary call each, falling back to unary calls when it is unavailable
+	Stream bool `yaml:"stream"`
+
+	// Reflection fetches each device's descriptors over gRPC server reflection
+	// and reports differences from the compiled-in protos. If fields moved,
+	// responses are decoded with the device's own schema.
+	Reflection bool `yaml:"reflection"`
+
+	// LogUnknownFields logs the first response carrying each field the
+	// compiled-in protos don't know, with a hex dump of the field
+	LogUnknownFields bool `yaml:"log_unknown_fields"`
+}
+
+// Collector contains the settings shared by every collector
+type Collector struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// DishCollector contains the dish collector settings
+type DishCollector struct {
+	Collector `yaml:",inline"`
+
+	// StatusMaxAge is how long a get_status response is reused by scrapes. A
+	// background poller refreshes it at this interval. 0 fetches on every scrape.
+	StatusMaxAge time.Duration `yaml:"status_max_age"`
+}
+
+// StatusFieldsCollector contains the settings for the generic get_status field
+// mapper. Fields are addressed by dotted path, e.g. "obstruction_stats.valid_s".
+type StatusFieldsCollector struct {
+	Enabled bool              `yaml:"enabled"`
+	Allow   []string          `yaml:"allow"`  // Field path patterns to export (all if empty)
+	Deny    []string          `yaml:"deny"`   // Field path patterns to skip, overriding allow
+	Rename  map[string]string `yaml:"rename"` // Metric names by field path
+}
+
+// Collectors contains per-collector enablement and polling intervals
+type Collectors struct {
+	Dish         DishCollector         `yaml:"dish"`          // Status gauges and history counters
+	Router       Collector             `yaml:"router"`        // Radio stats and router history (requires targets.router)
+	Mesh         Collector             `yaml:"mesh"`          // Mesh nodes and backhaul (requires targets.router)
+	SelfTest     Collector             `yaml:"self_test"`     // Scheduled self-tests (interval 0 = on demand only)
+	StatusFields StatusFieldsCollector `yaml:"status_fields"` // A gauge per get_status field
+}
+
+// HTTP contains HTTP server settings. These are only read at startup.
+type HTTP struct {
+	Listen       string        `yaml:"listen"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+
+	// WebConfigFile is an exporter-toolkit web config enabling TLS, mutual TLS
+	// and basic auth. Certificates and users are re-read on every connection.
+	WebConfigFile string `yaml:"web_config_file"`
+
+	// DisableMetrics turns off the /metrics endpoint, for setups that only push
+	DisableMetrics bool `yaml:"disable_metrics"`
+}
+
+// RemoteWrite contains the settings for pushing metrics to a Prometheus
+// remote_write endpoint. Pushing is disabled while URL is empty.
+type RemoteWrite struct {
+	URL      string        `yaml:"url"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+
+	// QueueDir keeps unsent batches on disk so they survive restarts. If empty,
+	// batches are queued in memory.
+	QueueDir      string `yaml:"queue_dir"`
+	QueueMaxBytes int64  `yaml:"queue_max_bytes"` // Oldest batches are dropped beyond this
+
+	BasicAuth       BasicAuth `yaml:"basic_auth"`
+	BearerToken     string    `yaml:"bearer_token"`
+	BearerTokenFile string    `yaml:"bearer_token_file"`
+}
+
+// BasicAuth contains HTTP basic auth credentials. Files are re-read on every
+// request so secrets can be rotated without a reload.
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// OTLP contains the settings for pushing metrics to an OpenTelemetry collector.
+// Export is disabled while Endpoint is empty.
+type OTLP struct {
+	// Endpoint is host:port for the grpc protocol, or the collector URL for http
+	// (/v1/metrics is appended if the URL has no path)
+	Endpoint string            `yaml:"endpoint"`
+	Protocol string            `yaml:"protocol"` // grpc or http
+	Insecure bool              `yaml:"insecure"` // Plaintext gRPC instead of TLS
+	Headers  map[string]string `yaml:"headers"`  // Sent with every export, e.g. authorization
+	Interval time.Duration     `yaml:"interval"`
+	Timeout  time.Duration     `yaml:"timeout"`
+}
+
+// InfluxDB contains the settings for writing per-second history samples and
+// periodic status to an InfluxDB v2 write endpoint. Writing is disabled while
+// URL is empty.
+type InfluxDB struct {
+	URL       string `yaml:"url"` // Server URL, e.g. http://influxdb:8086
+	Org       string `yaml:"org"`
+	Bucket    string `yaml:"bucket"`
+	Token     string `yaml:"token"`
+	TokenFile string `yaml:"token_file"` // Re-read on every write
+
+	StatusInterval time.Duration `yaml:"status_interval"` // How often status fields are written (0 = history only)
+	FlushInterval  time.Duration `yaml:"flush_interval"`  // How often buffered lines are sent
+	BatchSize      int           `yaml:"batch_size"`      // Most lines per write request
+	MaxBuffered    int           `yaml:"max_buffered"`    // Oldest lines are dropped beyond this while the server is unreachable
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// MQTT contains the settings for publishing dish state to an MQTT broker with
+// Home Assistant discovery. Publishing is disabled while Broker is empty.
+type MQTT struct {
+	Broker       string `yaml:"broker"`    // tcp://, ssl://, ws:// or wss:// URL
+	ClientID     string `yaml:"client_id"` // Defaults to starlink_exporter_<node_id>
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"` // Re-read on every connect
+
+	// Topics are <topic_prefix>/<node_id>/state and .../availability
+	TopicPrefix string        `yaml:"topic_prefix"`
+	NodeID      string        `yaml:"node_id"`
+	Interval    time.Duration `yaml:"interval"`
+	QoS         byte          `yaml:"qos"`
+
+	Discovery       bool   `yaml:"discovery"`        // Publish Home Assistant discovery configs
+	DiscoveryPrefix string `yaml:"discovery_prefix"` // Home Assistant's discovery prefix
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		LogLevel: "info",
+		Targets: Targets{
+			Dish:   "192.168.100.1:9200",
+			Stream: true,
+		},
+		Collectors: Collectors{
+			Dish:     DishCollector{Collector: Collector{Enabled: true, Interval: 1 * time.Second}, StatusMaxAge: 5 * time.Second},
+			Router:   Collector{Enabled: true, Interval: 1 * time.Second},
+			Mesh:     Collector{Enabled: false},
+			SelfTest: Collector{Enabled: true, Interval: 24 * time.Hour},
+		},
+		HTTP: HTTP{
+			Listen:       ":9999",
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		},
+		RemoteWrite: RemoteWrite{
+			Interval:      30 * time.Second,
+			Timeout:       10 * time.Second,
+			QueueMaxBytes: 64 << 20,
+		},
+		OTLP: OTLP{
+			Protocol: "grpc",
+			Interval: 30 * time.Second,
+			Timeout:  10 * time.Second,
+		},
+		InfluxDB: InfluxDB{
+			StatusInterval: 10 * time.Second,
+			FlushInterval:  10 * time.Second,
+			BatchSize:      5000,
+			MaxBuffered:    100000,
+			Timeout:        10 * time.Second,
+		},
+		MQTT: MQTT{
+			TopicPrefix:     "starlink",
+			NodeID:          "dish",
+			Interval:        10 * time.Second,
+			Discovery:       true,
+			DiscoveryPrefix: "homeassistant",
+		},
+	}
+}
+
+// Load reads the YAML file at path on top of a copy of base and validates the
+// result. Unknown keys are rejected so typos don't silently fall back to defaults.
+func Load(path string, base *Config) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	return Parse(data, base)
+}
+
+// Parse decodes YAML data on top of a copy of base and validates the result
+func Parse(data []byte, base *Config) (*Config, error) {
+	cfg := base.Clone()
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config: %v", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Clone returns a deep copy of the configuration
+func (c *Config) Clone() *Config {
+	clone := *c
+	if c.Labels != nil {
+		clone.Labels = make(map[string]string, len(c.Labels))
+		for k, v := range c.Labels {
+			clone.Labels[k] = v
+		}
+	}
+	if c.OTLP.Headers != nil {
+		clone.OTLP.Headers = make(map[string]string, len(c.OTLP.Headers))
+		for k, v := range c.OTLP.Headers {
+			clone.OTLP.Headers[k] = v
+		}
+	}
+	clone.Collectors.StatusFields.Allow = slices.Clone(c.Collectors.StatusFields.Allow)
+	clone.Collectors.StatusFields.Deny = slices.Clone(c.Collectors.StatusFields.Deny)
+	if c.Collectors.StatusFields.Rename != nil {
+		clone.Collectors.StatusFields.Rename = make(map[string]string, len(c.Collectors.StatusFields.Rename))
+		for k, v := range c.Collectors.StatusFields.Rename {
+			clone.Collectors.StatusFields.Rename[k] = v
+		}
+	}
+	return &clone
+}
+
+// Validate checks the configuration for errors, reporting all of them at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log_level: unknown level %q", c.LogLevel))
+	}
+
+	if c.Collectors.Dish.Enabled || c.Collectors.SelfTest.Enabled || c.Collectors.StatusFields.Enabled {
+		if err := validateAddress(c.Targets.Dish); err != nil {
+			errs = append(errs, fmt.Errorf("targets.dish: %v", err))
+		}
+	}
+	if c.Targets.Router != "" {
+		if err := validateAddress(c.Targets.Router); err != nil {
+			errs = append(errs, fmt.Errorf("targets.router: %v", err))
+		}
+	}
+	if c.Collectors.Mesh.Enabled && c.Targets.Router == "" {
+		errs = append(errs, errors.New("collectors.mesh: requires targets.router"))
+	}
+
+	if c.Collectors.Dish.Enabled && c.Collectors.Dish.Interval <= 0 {
+		errs = append(errs, errors.New("collectors.dish.interval: must be positive"))
+	}
+	if c.Collectors.Dish.StatusMaxAge < 0 {
+		errs = append(errs, errors.New("collectors.dish.status_max_age: must not be negative"))
+	}
+	if c.Collectors.Router.Enabled && c.Targets.Router != "" && c.Collectors.Router.Interval <= 0 {
+		errs = append(errs, errors.New("collectors.router.interval: must be positive"))
+	}
+	if c.Collectors.SelfTest.Interval < 0 {
+		errs = append(errs, errors.New("collectors.self_test.interval: must not be negative"))
+	}
+	if c.Collectors.StatusFields.Enabled {
+		errs = append(errs, c.Collectors.StatusFields.validate()...)
+	}
+
+	for name := range c.Labels {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			errs = append(errs, fmt.Errorf("labels: invalid label name %q", name))
+		}
+	}
+
+	if c.HTTP.Listen == "" {
+		errs = append(errs, errors.New("http.listen: must not be empty"))
+	}
+	if c.HTTP.ReadTimeout < 0 || c.HTTP.WriteTimeout < 0 || c.HTTP.IdleTimeout < 0 {
+		errs = append(errs, errors.New("http: timeouts must not be negative"))
+	}
+
+	if c.RemoteWrite.URL != "" {
+		errs = append(errs, c.RemoteWrite.validate()...)
+	}
+	if c.OTLP.Endpoint != "" {
+		errs = append(errs, c.OTLP.validate()...)
+	}
+	if c.MQTT.Broker != "" {
+		errs = append(errs, c.MQTT.validate()...)
+		if c.Targets.Dish == "" {
+			errs = append(errs, errors.New("mqtt: requires targets.dish"))
+		}
+	}
+	if c.InfluxDB.URL != "" {
+		errs = append(errs, c.InfluxDB.validate()...)
+		if c.Targets.Dish == "" {
+			errs = append(errs, errors.New("influxdb: requires targets.dish"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate checks the field patterns and metric names
+func (s *StatusFieldsCollector) validate() []error {
+	var errs []error
+	for _, pattern := range slices.Concat(s.Allow, s.Deny) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("collectors.status_fields: invalid pattern %q", pattern))
+		}
+	}
+	names := make(map[string]string, len(s.Rename))
+	for field, name := range s.Rename {
+		if !metricNameRE.MatchString(name) {
+			errs = append(errs, fmt.Errorf("collectors.status_fields.rename: invalid metric name %q for %s", name, field))
+		}
+		if other, ok := names[name]; ok {
+			errs = append(errs, fmt.Errorf("collectors.status_fields.rename: %s and %s both renamed to %q", min(field, other), max(field, other), name))
+		}
+		names[name] = field
+	}
+	return errs
+}
+
+// mqttTopicRE matches topic levels without wildcards or separators
+var mqttTopicRE = regexp.MustCompile(`^[^/#+]+$`)
+
+// validate checks an enabled mqtt section
+func (m *MQTT) validate() []error {
+	var errs []error
+	if u, err := url.Parse(m.Broker); err != nil || u.Host == "" {
+		errs = append(errs, fmt.Errorf("mqtt.broker: %q must be a URL like tcp://broker:1883", m.Broker))
+	} else {
+		switch u.Scheme {
+		case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+		default:
+			errs = append(errs, fmt.Errorf("mqtt.broker: unsupported scheme %q", u.Scheme))
+		}
+	}
+	if m.Password != "" && m.PasswordFile != "" {
+		errs = append(errs, errors.New("mqtt: password and password_file are mutually exclusive"))
+	}
+	if !mqttTopicRE.MatchString(m.NodeID) {
+		errs = append(errs, fmt.Errorf("mqtt.node_id: %q must be a single topic level", m.NodeID))
+	}
+	if m.TopicPrefix == "" || strings.ContainsAny(m.TopicPrefix, "#+") {
+		errs = append(errs, fmt.Errorf("mqtt.topic_prefix: %q must be non-empty without wildcards", m.TopicPrefix))
+	}
+	if m.Discovery && !mqttTopicRE.MatchString(m.DiscoveryPrefix) {
+		errs = append(errs, fmt.Errorf("mqtt.discovery_prefix: %q must be a single topic level", m.DiscoveryPrefix))
+	}
+	if m.Interval <= 0 {
+		errs = append(errs, errors.New("mqtt.interval: must be positive"))
+	}
+	if m.QoS > 2 {
+		errs = append(errs, errors.New("mqtt.qos: must be 0, 1 or 2"))
+	}
+	return errs
+}
+
+// validate checks an enabled influxdb section
+func (i *InfluxDB) validate() []error {
+	var errs []error
+	if u, err := url.Parse(i.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("influxdb.url: %q must be an http or https URL", i.URL))
+	}
+	if i.Org == "" {
+		errs = append(errs, errors.New("influxdb.org: must not be empty"))
+	}
+	if i.Bucket == "" {
+		errs = append(errs, errors.New("influxdb.bucket: must not be empty"))
+	}
+	if i.Token != "" && i.TokenFile != "" {
+		errs = append(errs, errors.New("influxdb: token and token_file are mutually exclusive"))
+	}
+	if i.StatusInterval < 0 {
+		errs = append(errs, errors.New("influxdb.status_interval: must not be negative"))
+	}
+	if i.FlushInterval <= 0 || i.Timeout <= 0 {
+		errs = append(errs, errors.New("influxdb: flush_interval and timeout must be positive"))
+	}
+	if i.BatchSize <= 0 || i.MaxBuffered < i.BatchSize {
+		errs = append(errs, errors.New("influxdb: batch_size must be positive and no larger than max_buffered"))
+	}
+	return errs
+}
+
+// validate checks an enabled otlp section
+func (o *OTLP) validate() []error {
+	var errs []error
+	switch o.Protocol {
+	case "grpc":
+		if err := validateAddress(o.Endpoint); err != nil {
+			errs = append(errs, fmt.Errorf("otlp.endpoint: %v", err))
+		}
+	case "http":
+		if u, err := url.Parse(o.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("otlp.endpoint: %q must be an http or https URL", o.Endpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("otlp.protocol: unknown protocol %q (want grpc or http)", o.Protocol))
+	}
+	if o.Interval <= 0 {
+		errs = append(errs, errors.New("otlp.interval: must be positive"))
+	}
+	if o.Timeout <= 0 {
+		errs = append(errs, errors.New("otlp.timeout: must be positive"))
+	}
+	return errs
+}
+
+// validate checks an enabled remote_write section
+func (rw *RemoteWrite) validate() []error {
+	var errs []error
+	if u, err := url.Parse(rw.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("remote_write.url: %q must be an http or https URL", rw.URL))
+	}
+	if rw.Interval <= 0 {
+		errs = append(errs, errors.New("remote_write.interval: must be positive"))
+	}
+	if rw.Timeout <= 0 {
+		errs = append(errs, errors.New("remote_write.timeout: must be positive"))
+	}
+	if rw.QueueMaxBytes <= 0 {
+		errs = append(errs, errors.New("remote_write.queue_max_bytes: must be positive"))
+	}
+
+	basic := rw.BasicAuth != BasicAuth{}
+	if basic && rw.BasicAuth.Username == "" {
+		errs = append(errs, errors.New("remote_write.basic_auth.username: must not be empty"))
+	}
+	if rw.BasicAuth.Password != "" && rw.BasicAuth.PasswordFile != "" {
+		errs = append(errs, errors.New("remote_write.basic_auth: password and password_file are mutually exclusive"))
+	}
+	if rw.BearerToken != "" && rw.BearerTokenFile != "" {
+		errs = append(errs, errors.New("remote_write: bearer_token and bearer_token_file are mutually exclusive"))
+	}
+	if basic && (rw.BearerToken != "" || rw.BearerTokenFile != "") {
+		errs = append(errs, errors.New("remote_write: basic_auth and bearer token are mutually exclusive"))
+	}
+	return errs
+}
+
+// validateAddress checks that addr is a host:port pair
+func validateAddress(addr string) error {
+	if addr == "" {
+		return errors.New("must not be empty")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" || port == "" {
+		return fmt.Errorf("%q must be host:port", addr)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse_OverridesDefaults(t *testing.T) {
+	cfg, err := Parse([]byte(`
+targets:
+  router: 192.168.1.1:9000
+collectors:
+  dish:
+    interval: 5s
+    status_max_age: 2s
+  mesh:
+    enabled: true
+labels:
+  site: warehouse-1
+`), Default())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if cfg.Targets.Dish != "192.168.100.1:9200" {
+		t.Errorf("Expected default dish address to be kept, got %q", cfg.Targets.Dish)
+	}
+	if cfg.Targets.Router != "192.168.1.1:9000" {
+		t.Errorf("Expected router address from file, got %q", cfg.Targets.Router)
+	}
+	if !cfg.Collectors.Dish.Enabled || cfg.Collectors.Dish.Interval != 5*time.Second {
+		t.Errorf("Expected dish collector enabled at 5s, got %+v", cfg.Collectors.Dish)
+	}
+	if cfg.Collectors.Dish.StatusMaxAge != 2*time.Second {
+		t.Errorf("Expected status max age 2s, got %v", cfg.Collectors.Dish.StatusMaxAge)
+	}
+	if !cfg.Collectors.Mesh.Enabled {
+		t.Error("Expected mesh collector to be enabled")
+	}
+	if cfg.Labels["site"] != "warehouse-1" {
+		t.Errorf("Expected site label, got %v", cfg.Labels)
+	}
+}
+
+func TestParse_EmptyFile(t *testing.T) {
+	cfg, err := Parse(nil, Default())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if cfg.HTTP.Listen != ":9999" {
+		t.Errorf("Expected default listen address, got %q", cfg.HTTP.Listen)
+	}
+}
+
+func TestParse_DoesNotModifyBase(t *testing.T) {
+	base := Default()
+	base.Labels = map[string]string{"site": "a"}
+
+	if _, err := Parse([]byte("labels:\n  site: b\n"), base); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if base.Labels["site"] != "a" {
+		t.Errorf("Expected base labels to be unchanged, got %v", base.Labels)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown key", "targets:\n  dishy: 1.2.3.4:9200\n", "field dishy not found"},
+		{"bad address", "targets:\n  dish: 192.168.100.1\n", "targets.dish"},
+		{"mesh without router", "collectors:\n  mesh:\n    enabled: true\n", "collectors.mesh"},
+		{"zero interval", "collectors:\n  dish:\n    interval: 0s\n", "collectors.dish.interval"},
+		{"bad label", "labels:\n  bad-name: x\n", "invalid label name"},
+		{"bad field pattern", "collectors:\n  status_fields:\n    enabled: true\n    deny: [\"config.[\"]\n", "invalid pattern"},
+		{"bad rename", "collectors:\n  status_fields:\n    enabled: true\n    rename:\n      eth_speed_mbps: eth-speed\n", "invalid metric name"},
+		{"bad log level", "log_level: verbose\n", "log_level"},
+		{"bad remote_write url", "remote_write:\n  url: localhost:9090\n", "remote_write.url"},
+		{"otlp grpc url", "otlp:\n  endpoint: http://collector:4317\n", "otlp.endpoint"},
+		{"otlp protocol", "otlp:\n  endpoint: collector:4317\n  protocol: thrift\n", "otlp.protocol"},
+		{"influxdb without bucket", "influxdb:\n  url: http://influxdb:8086\n  org: noc\n", "influxdb.bucket"},
+		{"mqtt node id", "mqtt:\n  broker: tcp://broker:1883\n  node_id: a/b\n", "mqtt.node_id"},
+		{"remote_write two auths", "remote_write:\n  url: https://example.com/push\n  bearer_token: x\n  basic_auth:\n    username: u\n", "mutually exclusive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.yaml), Default())
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+// Package config loads and validates the exporter's YAML configuration file.
+//
+// The configuration describes the dish and router targets, which collectors are
+// enabled and how often they poll, constant labels added to every metric, and
+// HTTP server settings. Command-line flags provide the defaults, so a config file
+// only needs to contain the settings it changes.
+package config
@@ -0,0 +1,39 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	h := NewHandler()
+
+	tests := []struct {
+		path        string
+		code        int
+		contentType string
+		contains    string
+	}{
+		{"/", http.StatusOK, "text/html", `src="dashboard/app.js"`},
+		{"/dashboard/app.js", http.StatusOK, "text/javascript", "api/v1/events"},
+		{"/dashboard/style.css", http.StatusOK, "text/css", "--down"},
+		{"/dashboard/missing.js", http.StatusNotFound, "", ""},
+		{"/other", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: code = %d, want %d", tt.path, rec.Code, tt.code)
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+			t.Errorf("%s: Content-Type = %q, want %s", tt.path, ct, tt.contentType)
+		}
+		if !strings.Contains(rec.Body.String(), tt.contains) {
+			t.Errorf("%s: body doesn't contain %q", tt.path, tt.contains)
+		}
+	}
+}
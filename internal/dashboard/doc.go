@@ -0,0 +1,6 @@
+// Package dashboard serves a self-contained status page for the dish: live
+// throughput and latency charts, alerts, an outage timeline, the obstruction
+// sky-plot and device info. The page is embedded in the binary and reads the
+// JSON API, with live updates from its Server-Sent Events stream, so it needs
+// nothing but the exporter.
+package dashboard
@@ -0,0 +1,463 @@
+// Starlink exporter dashboard. Reads the exporter's JSON API; history and status
+// arrive over the /api/v1/events Server-Sent Events stream.
+"use strict";
+
+const WINDOW_MS = 15 * 60 * 1000; // Chart span, the length of the dish's history buffer
+const OBSTRUCTION_REFRESH_MS = 60 * 1000;
+
+const state = {
+  samples: [], // Oldest first: {time: Date, down, up, latency, drop, power}
+  status: null,
+  statusError: null,
+  obstruction: null,
+};
+
+// --- Formatting ---
+
+function isNum(v) {
+  return typeof v === "number" && isFinite(v);
+}
+
+function fmtBps(bps) {
+  if (!isNum(bps)) return "–";
+  if (bps >= 1e9) return (bps / 1e9).toFixed(2) + " Gbps";
+  if (bps >= 1e6) return (bps / 1e6).toFixed(1) + " Mbps";
+  return (bps / 1e3).toFixed(0) + " kbps";
+}
+
+function fmtNum(v, digits, unit) {
+  return isNum(v) ? v.toFixed(digits) + unit : "–";
+}
+
+function fmtDuration(seconds) {
+  if (!isNum(seconds)) return "–";
+  seconds = Math.floor(seconds);
+  const d = Math.floor(seconds / 86400);
+  const h = Math.floor((seconds % 86400) / 3600);
+  const m = Math.floor((seconds % 3600) / 60);
+  const s = seconds % 60;
+  if (d > 0) return d + "d " + h + "h " + m + "m";
+  if (h > 0) return h + "h " + m + "m";
+  if (m > 0) return m + "m " + s + "s";
+  return s + "s";
+}
+
+function fmtTime(date) {
+  return date.toLocaleTimeString([], { hour: "2-digit", minute: "2-digit", second: "2-digit" });
+}
+
+// "thermal_throttle" -> "Thermal throttle"
+function humanize(name) {
+  const s = name.replace(/_/g, " ");
+  return s.charAt(0).toUpperCase() + s.slice(1);
+}
+
+function el(id) {
+  return document.getElementById(id);
+}
+
+// --- Data ---
+
+function toSample(s) {
+  return {
+    time: new Date(s.time),
+    down: s.downlinkThroughputBps,
+    up: s.uplinkThroughputBps,
+    latency: s.popPingLatencyMs,
+    drop: s.popPingDropRate,
+    power: s.powerInWatts,
+  };
+}
+
+function trimSamples() {
+  if (state.samples.length === 0) return;
+  const cutoff = state.samples[state.samples.length - 1].time - WINDOW_MS;
+  let i = 0;
+  while (i < state.samples.length && state.samples[i].time <= cutoff) i++;
+  state.samples.splice(0, i);
+}
+
+// Runs of seconds where every ping was dropped, oldest first
+function outages() {
+  const runs = [];
+  let start = null;
+  let prev = null;
+  for (const s of state.samples) {
+    if (isNum(s.drop) && s.drop >= 1) {
+      if (start === null) start = s.time;
+      prev = s.time;
+    } else if (start !== null) {
+      runs.push({ start: start, seconds: (prev - start) / 1000 + 1, ongoing: false });
+      start = null;
+    }
+  }
+  if (start !== null) {
+    runs.push({ start: start, seconds: (prev - start) / 1000 + 1, ongoing: true });
+  }
+  return runs;
+}
+
+// --- Canvas helpers ---
+
+// prepare sizes a canvas for the device pixel ratio and returns its context
+// along with its CSS size
+function prepare(canvas) {
+  const ratio = window.devicePixelRatio || 1;
+  const width = canvas.clientWidth;
+  const height = canvas.clientHeight;
+  if (canvas.width !== Math.round(width * ratio) || canvas.height !== Math.round(height * ratio)) {
+    canvas.width = Math.round(width * ratio);
+    canvas.height = Math.round(height * ratio);
+  }
+  const ctx = canvas.getContext("2d");
+  ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+  ctx.clearRect(0, 0, width, height);
+  return { ctx: ctx, width: width, height: height };
+}
+
+function cssVar(name) {
+  return getComputedStyle(document.documentElement).getPropertyValue(name).trim();
+}
+
+// niceMax rounds v up to 1, 2 or 5 times a power of ten
+function niceMax(v) {
+  if (!(v > 0)) return 1;
+  const p = Math.pow(10, Math.floor(Math.log10(v)));
+  for (const m of [1, 2, 5, 10]) {
+    if (v <= m * p) return m * p;
+  }
+  return 10 * p;
+}
+
+// drawChart plots series (each {key, color}) over the last WINDOW_MS, with the
+// y axis labelled by format
+function drawChart(canvas, series, format) {
+  const { ctx, width, height } = prepare(canvas);
+  const pad = { left: 70, right: 10, top: 8, bottom: 20 };
+  const w = width - pad.left - pad.right;
+  const h = height - pad.top - pad.bottom;
+  const samples = state.samples;
+  const muted = cssVar("--muted");
+  const border = cssVar("--border");
+
+  const end = samples.length ? samples[samples.length - 1].time.getTime() : Date.now();
+  const start = end - WINDOW_MS;
+  let max = 0;
+  for (const s of samples) {
+    for (const sr of series) {
+      if (isNum(s[sr.key])) max = Math.max(max, s[sr.key]);
+    }
+  }
+  max = niceMax(max);
+
+  const x = (t) => pad.left + ((t - start) / WINDOW_MS) * w;
+  const y = (v) => pad.top + h - (v / max) * h;
+
+  ctx.font = "11px system-ui, sans-serif";
+  ctx.fillStyle = muted;
+  ctx.strokeStyle = border;
+  ctx.lineWidth = 1;
+
+  // Horizontal grid with y labels
+  ctx.textAlign = "right";
+  ctx.textBaseline = "middle";
+  for (let i = 0; i <= 4; i++) {
+    const v = (max * i) / 4;
+    const yy = Math.round(y(v)) + 0.5;
+    ctx.beginPath();
+    ctx.moveTo(pad.left, yy);
+    ctx.lineTo(pad.left + w, yy);
+    ctx.stroke();
+    ctx.fillText(format(v), pad.left - 6, yy);
+  }
+
+  // Time labels every 5 minutes back from the newest sample
+  ctx.textAlign = "center";
+  ctx.textBaseline = "top";
+  for (let m = 15; m >= 0; m -= 5) {
+    ctx.fillText(m === 0 ? "now" : "-" + m + "m", x(end - m * 60000), pad.top + h + 4);
+  }
+
+  for (const sr of series) {
+    ctx.strokeStyle = sr.color;
+    ctx.lineWidth = 1.5;
+    ctx.beginPath();
+    let drawing = false;
+    let prev = null;
+    for (const s of samples) {
+      const v = s[sr.key];
+      // Break the line on unmeasured values and on gaps in the samples
+      if (!isNum(v) || (prev !== null && s.time - prev > 2000)) {
+        drawing = false;
+      }
+      if (isNum(v)) {
+        const px = x(s.time.getTime());
+        const py = y(v);
+        if (drawing) ctx.lineTo(px, py);
+        else ctx.moveTo(px, py);
+        drawing = true;
+      }
+      prev = s.time;
+    }
+    ctx.stroke();
+  }
+}
+
+function drawTimeline(canvas) {
+  const { ctx, width, height } = prepare(canvas);
+  const samples = state.samples;
+  const end = samples.length ? samples[samples.length - 1].time.getTime() : Date.now();
+  const start = end - WINDOW_MS;
+  const colors = { ok: cssVar("--ok"), partial: cssVar("--partial"), outage: cssVar("--outage") };
+
+  ctx.fillStyle = cssVar("--border");
+  ctx.fillRect(0, 0, width, height);
+
+  const slot = width / (WINDOW_MS / 1000);
+  for (const s of samples) {
+    if (!isNum(s.drop)) continue;
+    ctx.fillStyle = s.drop >= 1 ? colors.outage : s.drop > 0 ? colors.partial : colors.ok;
+    const px = ((s.time.getTime() - start) / WINDOW_MS) * width;
+    ctx.fillRect(px, 0, Math.max(slot, 1), height);
+  }
+}
+
+// drawSkyPlot draws the obstruction map: the dish's view of the sky with the
+// zenith in the middle and north up. Clear directions are green, obstructed
+// ones red, and unseen ones are left dark.
+function drawSkyPlot(canvas) {
+  const { ctx, width, height } = prepare(canvas);
+  const size = Math.min(width, height);
+  const cx = width / 2;
+  const cy = height / 2;
+  const radius = size / 2 - 16;
+  const muted = cssVar("--muted");
+  const border = cssVar("--border");
+  const m = state.obstruction;
+
+  if (m && m.numRows > 0 && m.numCols > 0) {
+    // Render the map at its own resolution, then scale it into the circle
+    const img = document.createElement("canvas");
+    img.width = m.numCols;
+    img.height = m.numRows;
+    const ictx = img.getContext("2d");
+    const data = ictx.createImageData(m.numCols, m.numRows);
+    for (let i = 0; i < m.snr.length; i++) {
+      const v = m.snr[i];
+      if (!isNum(v) || v < 0) continue;
+      const q = Math.min(1, v);
+      data.data[i * 4] = Math.round(229 * (1 - q) + 46 * q);
+      data.data[i * 4 + 1] = Math.round(72 * (1 - q) + 160 * q);
+      data.data[i * 4 + 2] = Math.round(77 * (1 - q) + 90 * q);
+      data.data[i * 4 + 3] = 255;
+    }
+    ictx.putImageData(data, 0, 0);
+    ctx.save();
+    ctx.imageSmoothingEnabled = false;
+    ctx.beginPath();
+    ctx.arc(cx, cy, radius, 0, 2 * Math.PI);
+    ctx.clip();
+    ctx.drawImage(img, cx - radius, cy - radius, radius * 2, radius * 2);
+    ctx.restore();
+  }
+
+  // Elevation rings and compass points
+  ctx.strokeStyle = border;
+  ctx.lineWidth = 1;
+  for (const f of [1 / 3, 2 / 3, 1]) {
+    ctx.beginPath();
+    ctx.arc(cx, cy, radius * f, 0, 2 * Math.PI);
+    ctx.stroke();
+  }
+  ctx.fillStyle = muted;
+  ctx.font = "11px system-ui, sans-serif";
+  ctx.textAlign = "center";
+  ctx.textBaseline = "middle";
+  ctx.fillText("N", cx, cy - radius - 9);
+  ctx.fillText("S", cx, cy + radius + 9);
+  ctx.fillText("E", cx + radius + 9, cy);
+  ctx.fillText("W", cx - radius - 9, cy);
+
+  // Where the dish is pointing
+  const st = state.status;
+  const maxTheta = m && isNum(m.maxThetaDeg) && m.maxThetaDeg > 0 ? m.maxThetaDeg : 90;
+  if (st && isNum(st.boresightAzimuthDeg) && isNum(st.boresightElevationDeg)) {
+    const r = (Math.min(90 - st.boresightElevationDeg, maxTheta) / maxTheta) * radius;
+    const a = (st.boresightAzimuthDeg * Math.PI) / 180;
+    ctx.fillStyle = cssVar("--text");
+    ctx.beginPath();
+    ctx.arc(cx + r * Math.sin(a), cy - r * Math.cos(a), 4, 0, 2 * Math.PI);
+    ctx.fill();
+  }
+}
+
+// --- Rendering ---
+
+function renderStats() {
+  const last = state.samples.length ? state.samples[state.samples.length - 1] : null;
+  const st = state.status;
+  el("stat-down").textContent = fmtBps(last ? last.down : st && st.downlinkThroughputBps);
+  el("stat-up").textContent = fmtBps(last ? last.up : st && st.uplinkThroughputBps);
+  el("stat-latency").textContent = fmtNum(last ? last.latency : st && st.popPingLatencyMs, 0, " ms");
+  el("stat-drop").textContent = fmtNum(last && isNum(last.drop) ? last.drop * 100 : null, 1, " %");
+  el("stat-obstructed").textContent = fmtNum(
+    st && st.obstructionStats && isNum(st.obstructionStats.fractionObstructed) ? st.obstructionStats.fractionObstructed * 100 : null,
+    2, " %");
+  el("stat-power").textContent = fmtNum(last ? last.power : null, 0, " W");
+}
+
+function renderStatus() {
+  const st = state.status;
+  const badge = el("dish-state");
+  if (state.statusError) {
+    badge.textContent = "Dish unreachable";
+    badge.className = "badge bad";
+    badge.title = state.statusError;
+  } else if (st) {
+    badge.textContent = "Dish online";
+    badge.className = "badge good";
+    badge.title = "";
+  }
+  if (!st) return;
+
+  el("device-id").textContent = st.deviceInfo.id;
+
+  const alerts = el("alerts");
+  alerts.replaceChildren();
+  const names = st.alerts || [];
+  if (names.length === 0) {
+    const li = document.createElement("li");
+    li.className = "muted";
+    li.textContent = "No active alerts";
+    alerts.appendChild(li);
+  }
+  for (const name of names) {
+    const li = document.createElement("li");
+    li.className = "alert";
+    li.textContent = humanize(name);
+    alerts.appendChild(li);
+  }
+
+  const rows = [
+    ["ID", st.deviceInfo.id],
+    ["Hardware", st.deviceInfo.hardwareVersion],
+    ["Software", st.deviceInfo.softwareVersion],
+    ["Country", st.deviceInfo.countryCode],
+    ["Boot count", st.deviceInfo.bootcount],
+    ["Uptime", fmtDuration(st.deviceState.uptimeS)],
+    ["GPS", (st.gpsStats.gpsValid ? "valid" : "no fix") + ", " + st.gpsStats.gpsSats + " satellites"],
+    ["Ethernet", st.ethSpeedMbps ? st.ethSpeedMbps + " Mbps" : "–"],
+    ["Pointing", fmtNum(st.boresightAzimuthDeg, 1, "° az") + ", " + fmtNum(st.boresightElevationDeg, 1, "° el")],
+    ["SNR above noise floor", st.isSnrAboveNoiseFloor ? "yes" : "no"],
+  ];
+  const table = el("device");
+  table.replaceChildren();
+  for (const [label, value] of rows) {
+    const tr = table.insertRow();
+    tr.insertCell().textContent = label;
+    tr.insertCell().textContent = value === undefined || value === "" ? "–" : value;
+  }
+}
+
+function renderOutages() {
+  const list = el("outages");
+  list.replaceChildren();
+  const runs = outages().reverse();
+  if (runs.length === 0) {
+    const li = document.createElement("li");
+    li.className = "muted";
+    li.textContent = state.samples.length ? "No outages in the last 15 minutes" : "Waiting for history";
+    list.appendChild(li);
+  }
+  for (const run of runs) {
+    const li = document.createElement("li");
+    li.textContent = fmtTime(run.start) + " — " + fmtDuration(run.seconds) + (run.ongoing ? " (ongoing)" : "");
+    list.appendChild(li);
+  }
+}
+
+function renderCharts() {
+  drawChart(el("chart-throughput"), [
+    { key: "down", color: cssVar("--down") },
+    { key: "up", color: cssVar("--up") },
+  ], fmtBps);
+  drawChart(el("chart-latency"), [{ key: "latency", color: cssVar("--latency") }], (v) => v.toFixed(0) + " ms");
+  drawTimeline(el("timeline"));
+  drawSkyPlot(el("skyplot"));
+}
+
+let pending = false;
+function render() {
+  if (pending) return;
+  pending = true;
+  requestAnimationFrame(() => {
+    pending = false;
+    renderStats();
+    renderStatus();
+    renderOutages();
+    renderCharts();
+  });
+}
+
+// --- Data sources ---
+
+function connect() {
+  const streamState = el("stream-state");
+  const events = new EventSource("api/v1/events");
+
+  events.onopen = () => {
+    streamState.textContent = "Live";
+    streamState.className = "badge good";
+  };
+  events.onerror = () => {
+    // EventSource reconnects by itself, e.g. after a config reload
+    streamState.textContent = "Reconnecting";
+    streamState.className = "badge bad";
+  };
+
+  events.addEventListener("history", (e) => {
+    const msg = JSON.parse(e.data);
+    if (msg.error) return;
+    state.samples = msg.samples.map(toSample);
+    trimSamples();
+    render();
+  });
+  events.addEventListener("samples", (e) => {
+    const batch = JSON.parse(e.data).map(toSample);
+    const newest = state.samples.length ? state.samples[state.samples.length - 1].time : null;
+    for (const s of batch) {
+      if (newest === null || s.time > newest) state.samples.push(s);
+    }
+    trimSamples();
+    render();
+  });
+  events.addEventListener("status", (e) => {
+    const msg = JSON.parse(e.data);
+    if (msg.error) {
+      state.statusError = msg.error;
+    } else {
+      state.status = msg;
+      state.statusError = null;
+    }
+    render();
+  });
+}
+
+async function loadObstructionMap() {
+  const note = el("skyplot-note");
+  try {
+    const resp = await fetch("api/v1/obstruction_map");
+    const msg = await resp.json();
+    if (!resp.ok) throw new Error(msg.error || resp.statusText);
+    state.obstruction = msg;
+    note.textContent = "";
+  } catch (err) {
+    note.textContent = "Obstruction map unavailable: " + err.message;
+  }
+  render();
+}
+
+connect();
+loadObstructionMap();
+setInterval(loadObstructionMap, OBSTRUCTION_REFRESH_MS);
+window.addEventListener("resize", render);
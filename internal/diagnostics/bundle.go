@@ -0,0 +1,117 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// File is a single file inside a diagnostics bundle
+type File struct {
+	Name string
+	Data []byte
+}
+
+// bundleRequests are the dish requests included in every bundle, keyed by file name
+var bundleRequests = []struct {
+	name string
+	req  *pb.Request
+}{
+	{"diagnostics.json", &pb.Request{Request: &pb.Request_GetDiagnostics{GetDiagnostics: &pb.GetDiagnosticsRequest{}}}},
+	{"status.json", &pb.Request{Request: &pb.Request_GetStatus{GetStatus: &pb.GetStatusRequest{}}}},
+	{"history.json", &pb.Request{Request: &pb.Request_GetHistory{GetHistory: &pb.GetHistoryRequest{}}}},
+	{"obstruction_map.json", &pb.Request{Request: &pb.Request_DishGetObstructionMap{DishGetObstructionMap: &pb.DishGetObstructionMapRequest{}}}},
+	{"network_interfaces.json", &pb.Request{Request: &pb.Request_GetNetworkInterfaces{GetNetworkInterfaces: &pb.GetNetworkInterfacesRequest{}}}},
+}
+
+var marshalOptions = protojson.MarshalOptions{Multiline: true, Indent: "  "}
+
+// Collect fetches every bundle request from the dish and renders it as protojson,
+// followed by the exporter state as exporter.json. A failed request produces a
+// <name>.error.txt file instead, so a partially reachable dish still yields a bundle.
+func Collect(c client.RawClient, exporterState any, redact bool) []File {
+	files := make([]File, 0, len(bundleRequests)+1)
+
+	for _, br := range bundleRequests {
+		resp, err := c.Handle(br.req)
+		if err != nil {
+			files = append(files, File{Name: br.name + ".error.txt", Data: []byte(err.Error() + "\n")})
+			continue
+		}
+
+		if redact {
+			Redact(resp.ProtoReflect())
+		}
+
+		data, err := marshalOptions.Marshal(resp)
+		if err != nil {
+			files = append(files, File{Name: br.name + ".error.txt", Data: []byte(err.Error() + "\n")})
+			continue
+		}
+		files = append(files, File{Name: br.name, Data: data})
+	}
+
+	state, err := json.MarshalIndent(exporterState, "", "  ")
+	if err != nil {
+		files = append(files, File{Name: "exporter.json.error.txt", Data: []byte(err.Error() + "\n")})
+	} else {
+		files = append(files, File{Name: "exporter.json", Data: state})
+	}
+
+	return files
+}
+
+// WriteTarGz writes files as a gzipped tarball with every entry under dir/
+func WriteTarGz(w io.Writer, dir string, files []File, modTime time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    dir + "/" + f.Name,
+			Mode:    0o644,
+			Size:    int64(len(f.Data)),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write tar header: %v", err)
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return fmt.Errorf("failed to write tar entry: %v", err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar: %v", err)
+	}
+	return gz.Close()
+}
+
+// WriteZip writes files as a zip archive with every entry under dir/
+func WriteZip(w io.Writer, dir string, files []File, modTime time.Time) error {
+	zw := zip.NewWriter(w)
+
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     dir + "/" + f.Name,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write zip header: %v", err)
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return fmt.Errorf("failed to write zip entry: %v", err)
+		}
+	}
+
+	return zw.Close()
+}
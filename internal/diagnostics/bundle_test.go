@@ -0,0 +1,106 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+)
+
+type fakeRawClient struct{}
+
+func (fakeRawClient) Handle(req *pb.Request) (*pb.Response, error) {
+	switch req.Request.(type) {
+	case *pb.Request_GetDiagnostics:
+		return &pb.Response{Response: &pb.Response_DishGetDiagnostics{DishGetDiagnostics: &pb.DishGetDiagnosticsResponse{
+			Id:              "ut01234567-89abcdef",
+			HardwareVersion: "rev3_proto2",
+			Location: &pb.DishGetDiagnosticsResponse_Location{
+				Enabled:  true,
+				Latitude: 47.6,
+			},
+		}}}, nil
+	case *pb.Request_GetStatus:
+		return &pb.Response{Response: &pb.Response_DishGetStatus{DishGetStatus: &pb.DishGetStatusResponse{
+			DeviceInfo: &pb.DeviceInfo{Id: "ut01234567-89abcdef", CountryCode: "US"},
+		}}}, nil
+	default:
+		return nil, errors.New("unimplemented")
+	}
+}
+
+func TestCollect_Redact(t *testing.T) {
+	files := Collect(fakeRawClient{}, map[string]string{"dish": "192.168.100.1:9200"}, true)
+
+	byName := make(map[string]string)
+	for _, f := range files {
+		byName[f.Name] = string(f.Data)
+	}
+
+	diagnostics, ok := byName["diagnostics.json"]
+	if !ok {
+		t.Fatal("Expected diagnostics.json in bundle")
+	}
+	if strings.Contains(diagnostics, "ut01234567") || strings.Contains(diagnostics, "47.6") {
+		t.Errorf("Expected device ID and location to be redacted, got %s", diagnostics)
+	}
+	if !strings.Contains(diagnostics, "rev3_proto2") {
+		t.Errorf("Expected non-sensitive fields to be kept, got %s", diagnostics)
+	}
+
+	status := byName["status.json"]
+	if strings.Contains(status, "ut01234567") || !strings.Contains(status, "US") {
+		t.Errorf("Expected only the device ID to be redacted from status, got %s", status)
+	}
+
+	if _, ok := byName["history.json.error.txt"]; !ok {
+		t.Error("Expected failed request to be recorded as an error file")
+	}
+	if _, ok := byName["exporter.json"]; !ok {
+		t.Error("Expected exporter.json in bundle")
+	}
+}
+
+func TestCollect_NoRedact(t *testing.T) {
+	files := Collect(fakeRawClient{}, nil, false)
+	if !strings.Contains(string(files[0].Data), "ut01234567") {
+		t.Errorf("Expected device ID to be kept without redaction, got %s", files[0].Data)
+	}
+}
+
+func TestWriteTarGz(t *testing.T) {
+	files := []File{{Name: "a.json", Data: []byte("{}")}, {Name: "b.json", Data: []byte("[]")}}
+
+	var buf bytes.Buffer
+	if err := WriteTarGz(&buf, "bundle", files, time.Unix(0, 0)); err != nil {
+		t.Fatalf("WriteTarGz failed: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Invalid gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+
+	if strings.Join(names, ",") != "bundle/a.json,bundle/b.json" {
+		t.Errorf("Unexpected entries: %v", names)
+	}
+}
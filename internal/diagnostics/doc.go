@@ -0,0 +1,7 @@
+// Package diagnostics builds downloadable support bundles for a Starlink dish.
+//
+// A bundle is a tar.gz or zip archive containing protojson dumps of the dish's
+// diagnostics, status, history, obstruction map and network interfaces, plus a
+// JSON snapshot of the exporter's own state. Location and device identifiers can
+// optionally be redacted before the bundle leaves the exporter.
+package diagnostics
@@ -0,0 +1,100 @@
+package diagnostics
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+// bundleWriteTimeout bounds writing a collected bundle to the client
+const bundleWriteTimeout = 30 * time.Second
+
+// Handler serves diagnostics bundles over HTTP.
+//
+// Query parameters:
+//   - format: "tar.gz" (default) or "zip"
+//   - redact: "true" to strip location and device identifiers
+type Handler struct {
+	client client.RawClient
+	state  func() any
+	logger *slog.Logger
+}
+
+// NewHandler creates a diagnostics bundle handler. state is called on every
+// request to snapshot the exporter's own state into the bundle.
+func NewHandler(c client.RawClient, state func() any, logger *slog.Logger) *Handler {
+	return &Handler{
+		client: c,
+		state:  state,
+		logger: logger,
+	}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "tar.gz"
+	}
+	if format != "tar.gz" && format != "zip" {
+		http.Error(w, fmt.Sprintf("unsupported format %q (use tar.gz or zip)", format), http.StatusBadRequest)
+		return
+	}
+
+	redact := false
+	if v := r.URL.Query().Get("redact"); v != "" {
+		var err error
+		redact, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid redact value %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The bundle RPCs run one after another, each with its own timeout, and can
+	// take longer than the server's write timeout when the dish is slow. Lift the
+	// deadline while collecting and give the write its own.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Debug("Failed to clear write deadline for diagnostics bundle", "error", err)
+	}
+
+	now := time.Now().UTC()
+	dir := "starlink-diagnostics-" + now.Format("20060102T150405Z")
+	files := Collect(h.client, h.state(), redact)
+	if err := rc.SetWriteDeadline(time.Now().Add(bundleWriteTimeout)); err != nil {
+		h.logger.Debug("Failed to set write deadline for diagnostics bundle", "error", err)
+	}
+
+	// Build the archive in memory so a write failure can still return a 500
+	var buf bytes.Buffer
+	var err error
+	if format == "zip" {
+		err = WriteZip(&buf, dir, files, now)
+	} else {
+		err = WriteTarGz(&buf, dir, files, now)
+	}
+	if err != nil {
+		h.logger.Error("Failed to build diagnostics bundle", "error", err)
+		http.Error(w, "failed to build diagnostics bundle", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "application/gzip"
+	if format == "zip" {
+		contentType = "application/zip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", dir+"."+format))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := buf.WriteTo(w); err != nil {
+		h.logger.Debug("Failed to write diagnostics bundle", "error", err)
+	}
+
+	h.logger.Info("Diagnostics bundle served", "format", format, "redact", redact, "files", len(files))
+}
@@ -0,0 +1,62 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/R167/starlink_exporter/proto/spacex_api/device"
+)
+
+// slowRawClient answers like fakeRawClient after a delay, as a busy dish would
+type slowRawClient struct {
+	delay time.Duration
+}
+
+func (c slowRawClient) Handle(req *pb.Request) (*pb.Response, error) {
+	time.Sleep(c.delay)
+	return fakeRawClient{}.Handle(req)
+}
+
+func TestHandler_OutlivesWriteTimeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	h := NewHandler(slowRawClient{delay: 50 * time.Millisecond}, func() any { return nil }, logger)
+	srv := httptest.NewUnstartedServer(h)
+	// Shorter than collecting the whole bundle
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected a bundle despite the write timeout, got %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	entries := 0
+	for {
+		if _, err := tr.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Truncated bundle after %d entries: %v", entries, err)
+		}
+		entries++
+	}
+	if entries != len(bundleRequests)+1 {
+		t.Errorf("got %d entries, want %d", entries, len(bundleRequests)+1)
+	}
+}
@@ -0,0 +1,51 @@
+package diagnostics
+
+import "google.golang.org/protobuf/reflect/protoreflect"
+
+// redactedFields are proto field names that identify the device or its location
+var redactedFields = map[protoreflect.Name]bool{
+	// Device identifiers
+	"id":          true,
+	"device_id":   true,
+	"dish_id":     true,
+	"router_id":   true,
+	"mac_address": true,
+	"mac_lan":     true,
+	"mac_wan":     true,
+
+	// Location
+	"location":        true,
+	"lla":             true,
+	"latitude":        true,
+	"longitude":       true,
+	"altitude_meters": true,
+	"lat":             true,
+	"lon":             true,
+	"alt":             true,
+}
+
+// Redact clears device identifier and location fields anywhere in m, in place
+func Redact(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if redactedFields[fd.Name()] {
+			m.Clear(fd)
+			return true
+		}
+
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				Redact(list.Get(i).Message())
+			}
+		case fd.IsMap() && fd.MapValue().Message() != nil:
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				Redact(mv.Message())
+				return true
+			})
+		case fd.Message() != nil && !fd.IsMap():
+			Redact(v.Message())
+		}
+		return true
+	})
+}
@@ -0,0 +1,8 @@
+// Package influx writes the dish's per-second history samples and periodic
+// status to an InfluxDB v2 write endpoint as line protocol.
+//
+// Samples arrive from the bandwidth tracker as it integrates them, so InfluxDB
+// gets the dish's native one-second resolution rather than values averaged
+// over a scrape interval. Lines are buffered and sent in batches; while the
+// server is unreachable they stay buffered (up to a limit) and are retried.
+package influx
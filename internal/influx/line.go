@@ -0,0 +1,87 @@
+package influx
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Escapers for the parts of a line (see the InfluxDB line protocol reference)
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// tag is one tag key/value pair
+type tag struct {
+	key, value string
+}
+
+// field is one field of a line. Value must be a float64, int64, bool or string.
+type field struct {
+	key   string
+	value any
+}
+
+// sortedTags converts labels into tags sorted by key, as InfluxDB prefers
+func sortedTags(labels map[string]string) []tag {
+	tags := make([]tag, 0, len(labels))
+	for k, v := range labels {
+		tags = append(tags, tag{k, v})
+	}
+	slices.SortFunc(tags, func(a, b tag) int { return strings.Compare(a.key, b.key) })
+	return tags
+}
+
+// formatLine formats one line with a timestamp in seconds. Non-finite floats
+// are skipped since InfluxDB rejects them; it returns "" if no fields remain.
+func formatLine(measurement string, tags []tag, fields []field, ts time.Time) string {
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(measurement))
+	for _, t := range tags {
+		if t.value == "" {
+			continue
+		}
+		b.WriteByte(',')
+		b.WriteString(tagEscaper.Replace(t.key))
+		b.WriteByte('=')
+		b.WriteString(tagEscaper.Replace(t.value))
+	}
+
+	sep := byte(' ')
+	written := 0
+	for _, f := range fields {
+		var value string
+		switch v := f.value.(type) {
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case int64:
+			value = strconv.FormatInt(v, 10) + "i"
+		case bool:
+			value = strconv.FormatBool(v)
+		case string:
+			value = `"` + stringEscaper.Replace(v) + `"`
+		default:
+			continue
+		}
+		b.WriteByte(sep)
+		b.WriteString(tagEscaper.Replace(f.key))
+		b.WriteByte('=')
+		b.WriteString(value)
+		sep = ','
+		written++
+	}
+	if written == 0 {
+		return ""
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatInt(ts.Unix(), 10))
+	return b.String()
+}
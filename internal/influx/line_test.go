@@ -0,0 +1,49 @@
+package influx
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFormatLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []tag
+		fields []field
+		want   string
+	}{
+		{
+			name:   "types",
+			tags:   []tag{{"site", "cabin"}},
+			fields: []field{{"bps", 1500.5}, {"sats", int64(12)}, {"gps_valid", true}, {"id", "ut01"}},
+			want:   `starlink_status,site=cabin bps=1500.5,sats=12i,gps_valid=true,id="ut01" 1700000000`,
+		},
+		{
+			name:   "escaping",
+			tags:   []tag{{"site", "north cabin,a=b"}},
+			fields: []field{{"version", `say "hi" \`}},
+			want:   `starlink_status,site=north\ cabin\,a\=b version="say \"hi\" \\" 1700000000`,
+		},
+		{
+			name:   "skips non-finite and empty tags",
+			tags:   []tag{{"site", ""}},
+			fields: []field{{"latency", math.NaN()}, {"drop", 0.0}},
+			want:   `starlink_status drop=0 1700000000`,
+		},
+		{
+			name:   "no fields",
+			fields: []field{{"latency", math.Inf(1)}},
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatLine("starlink_status", tt.tags, tt.fields, time.Unix(1700000000, 0))
+			if got != tt.want {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package influx
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// InfluxDB writer self-metrics. They are package-level so counts survive config
+// reloads that replace the writer; register them once with MustRegisterMetrics.
+var (
+	linesWritten = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "starlink_exporter_influxdb_lines_written_total",
+		Help: "Total lines accepted by the InfluxDB write endpoint",
+	})
+	writeFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "starlink_exporter_influxdb_write_failures_total",
+		Help: "Total InfluxDB write requests that failed with a retryable error; the lines stay buffered",
+	})
+	linesDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_influxdb_lines_dropped_total",
+			Help: "Total lines dropped without being written by reason (rejected, buffer_full)",
+		},
+		[]string{"reason"},
+	)
+	bufferedLines = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "starlink_exporter_influxdb_buffered_lines",
+		Help: "Number of lines waiting to be written to InfluxDB",
+	})
+)
+
+// MustRegisterMetrics registers the InfluxDB writer self-metrics with reg
+func MustRegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(linesWritten, writeFailures, linesDropped, bufferedLines)
+}
@@ -0,0 +1,292 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+	"github.com/R167/starlink_exporter/internal/config"
+)
+
+// Measurement names
+const (
+	historyMeasurement = "starlink_history"
+	statusMeasurement  = "starlink_status"
+)
+
+// errRejected marks lines the server will never accept. They are dropped
+// instead of retried.
+var errRejected = errors.New("rejected")
+
+// Writer buffers history samples and status as line protocol and writes them
+// to InfluxDB in batches
+type Writer struct {
+	cfg      config.InfluxDB
+	status   client.Client // Status source; nil disables status lines
+	tags     []tag         // Added to every line
+	client   *http.Client
+	writeURL string
+	logger   *slog.Logger
+	failing  bool // Whether the last write failed, to log once per outage
+
+	mu      sync.Mutex
+	lines   []string // Buffered lines, oldest first
+	dropped int      // Lines dropped from the front of lines so far
+	full    chan struct{}
+
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewWriter creates a writer. labels become tags on every line; status may be
+// nil to write history samples only.
+func NewWriter(cfg config.InfluxDB, labels map[string]string, status client.Client, logger *slog.Logger) (*Writer, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid InfluxDB URL: %v", err)
+	}
+	u = u.JoinPath("api/v2/write")
+	u.RawQuery = url.Values{"org": {cfg.Org}, "bucket": {cfg.Bucket}, "precision": {"s"}}.Encode()
+
+	return &Writer{
+		cfg:       cfg,
+		status:    status,
+		tags:      sortedTags(labels),
+		client:    &http.Client{Timeout: cfg.Timeout},
+		writeURL:  u.String(),
+		logger:    logger.With("component", "influxdb"),
+		full:      make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}, nil
+}
+
+// AddSamples buffers history samples. It is a collector.SampleHandler and
+// never blocks.
+func (w *Writer) AddSamples(samples []collector.HistorySample) {
+	lines := make([]string, 0, len(samples))
+	for _, s := range samples {
+		line := formatLine(historyMeasurement, w.tags, []field{
+			{"downlink_throughput_bps", s.DownlinkThroughputBps},
+			{"uplink_throughput_bps", s.UplinkThroughputBps},
+			{"pop_ping_latency_ms", s.PopPingLatencyMs},
+			{"pop_ping_drop_rate", s.PopPingDropRate},
+			{"power_in_watts", s.PowerInWatts},
+		}, s.Time)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	w.add(lines)
+}
+
+// InheritState takes over the unsent lines of a stopped writer so a reload
+// doesn't lose them
+func (w *Writer) InheritState(prev *Writer) {
+	prev.mu.Lock()
+	lines := prev.lines
+	prev.lines = nil
+	prev.mu.Unlock()
+
+	w.mu.Lock()
+	w.lines = append(lines, w.lines...)
+	w.mu.Unlock()
+	w.add(nil)
+}
+
+// Start writes status every status interval and flushes buffered lines every
+// flush interval (or sooner once a batch is full) until ctx is cancelled or Stop
+// is called
+func (w *Writer) Start(ctx context.Context) {
+	flush := time.NewTicker(w.cfg.FlushInterval)
+	defer flush.Stop()
+	var statusC <-chan time.Time
+	if w.status != nil && w.cfg.StatusInterval > 0 {
+		status := time.NewTicker(w.cfg.StatusInterval)
+		defer status.Stop()
+		statusC = status.C
+	}
+	defer close(w.stoppedCh)
+
+	w.logger.Info("InfluxDB writer started", "url", w.cfg.URL, "bucket", w.cfg.Bucket)
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("InfluxDB writer stopping")
+			return
+		case <-w.stopCh:
+			w.logger.Info("InfluxDB writer stopping")
+			return
+		case <-statusC:
+			w.addStatus()
+		case <-w.full:
+			w.flush(ctx)
+		case <-flush.C:
+			w.flush(ctx)
+		}
+	}
+}
+
+// Stop stops the writer (safe to call multiple times). Unsent lines stay buffered.
+func (w *Writer) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+	<-w.stoppedCh
+}
+
+// addStatus buffers one status line
+func (w *Writer) addStatus() {
+	status, err := w.status.GetStatus()
+	if err != nil {
+		// History lines stop too, and starlink_up reports the outage
+		w.logger.Debug("Failed to get status", "error", err)
+		return
+	}
+
+	line := formatLine(statusMeasurement, w.tags, []field{
+		{"id", status.DeviceInfo.ID},
+		{"hardware_version", status.DeviceInfo.HardwareVersion},
+		{"software_version", status.DeviceInfo.SoftwareVersion},
+		{"uptime_s", int64(status.DeviceState.UptimeS)},
+		{"downlink_throughput_bps", status.DownlinkThroughputBps},
+		{"uplink_throughput_bps", status.UplinkThroughputBps},
+		{"pop_ping_latency_ms", status.PopPingLatencyMs},
+		{"fraction_obstructed", status.ObstructionStats.FractionObstructed},
+		{"boresight_azimuth_deg", status.BoresightAzimuthDeg},
+		{"boresight_elevation_deg", status.BoresightElevationDeg},
+		{"gps_valid", status.GPSStats.GPSValid},
+		{"gps_sats", int64(status.GPSStats.GPSSats)},
+		{"eth_speed_mbps", int64(status.EthSpeedMbps)},
+		{"snr_above_noise_floor", status.IsSnrAboveNoiseFloor},
+	}, time.Now())
+	if line != "" {
+		w.add([]string{line})
+	}
+}
+
+// add appends lines to the buffer, dropping the oldest beyond the limit, and
+// wakes the flusher once a batch is full
+func (w *Writer) add(lines []string) {
+	w.mu.Lock()
+	w.lines = append(w.lines, lines...)
+	dropped := len(w.lines) - w.cfg.MaxBuffered
+	if dropped > 0 {
+		w.lines = append(w.lines[:0:0], w.lines[dropped:]...)
+		w.dropped += dropped
+	}
+	n := len(w.lines)
+	w.mu.Unlock()
+
+	if dropped > 0 {
+		linesDropped.WithLabelValues("buffer_full").Add(float64(dropped))
+		w.logger.Debug("InfluxDB buffer full, dropped oldest lines", "dropped", dropped)
+	}
+	bufferedLines.Set(float64(n))
+	if n >= w.cfg.BatchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flush writes buffered lines in batches until the buffer is empty or a write
+// fails with a retryable error, which is retried on the next flush
+func (w *Writer) flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		w.mu.Lock()
+		batch := w.lines[:min(len(w.lines), w.cfg.BatchSize)]
+		droppedBefore := w.dropped
+		w.mu.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+
+		err := w.write(ctx, batch)
+		switch {
+		case err == nil:
+			linesWritten.Add(float64(len(batch)))
+			if w.failing {
+				w.logger.Info("InfluxDB write recovered")
+				w.failing = false
+			}
+		case errors.Is(err, errRejected):
+			linesDropped.WithLabelValues("rejected").Add(float64(len(batch)))
+			w.logger.Error("InfluxDB rejected batch, dropping it", "error", err, "lines", len(batch))
+		default:
+			writeFailures.Inc()
+			if !w.failing {
+				w.logger.Warn("InfluxDB write failed, will retry", "error", err)
+				w.failing = true
+			} else {
+				w.logger.Debug("InfluxDB write failed, will retry", "error", err)
+			}
+			return
+		}
+
+		// Lines are only appended, so whatever the buffer didn't drop meanwhile
+		// is still at the front
+		w.mu.Lock()
+		if remaining := len(batch) - (w.dropped - droppedBefore); remaining > 0 {
+			w.lines = w.lines[remaining:]
+		}
+		n := len(w.lines)
+		w.mu.Unlock()
+		bufferedLines.Set(float64(n))
+	}
+}
+
+// write sends one batch. Errors wrapping errRejected must not be retried.
+func (w *Writer) write(ctx context.Context, batch []string) error {
+	token := w.cfg.Token
+	if w.cfg.TokenFile != "" {
+		data, err := os.ReadFile(w.cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	body := strings.Join(batch, "\n") + "\n"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.writeURL, bytes.NewBufferString(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRejected, err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", "starlink_exporter")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		// Malformed or out-of-retention lines; retrying won't help. Auth and
+		// missing bucket errors are retried so a fixed config loses nothing.
+		return fmt.Errorf("%w: server returned %s: %s", errRejected, resp.Status, strings.TrimSpace(string(msg)))
+	default:
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+}
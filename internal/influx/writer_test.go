@@ -0,0 +1,102 @@
+package influx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/collector"
+	"github.com/R167/starlink_exporter/internal/config"
+)
+
+func newTestWriter(t *testing.T, url string) *Writer {
+	t.Helper()
+	cfg := config.Default().InfluxDB
+	cfg.URL = url
+	cfg.Org = "noc"
+	cfg.Bucket = "starlink"
+	cfg.Token = "secret"
+	cfg.BatchSize = 2
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	w, err := NewWriter(cfg, map[string]string{"site": "cabin"}, nil, logger)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	return w
+}
+
+func samples(n int) []collector.HistorySample {
+	s := make([]collector.HistorySample, n)
+	for i := range s {
+		s[i] = collector.HistorySample{Time: time.Unix(int64(1700000000+i), 0), DownlinkThroughputBps: float64(i)}
+	}
+	return s
+}
+
+func TestWriter_Flush(t *testing.T) {
+	var requests []string
+	var query, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, auth = r.URL.RawQuery, r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w := newTestWriter(t, srv.URL)
+	w.AddSamples(samples(3))
+	w.flush(context.Background())
+
+	if query != "bucket=starlink&org=noc&precision=s" || auth != "Token secret" {
+		t.Errorf("Unexpected query %q or auth %q", query, auth)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("Expected 3 lines in 2 batches, got %d requests", len(requests))
+	}
+	want := "starlink_history,site=cabin downlink_throughput_bps=0,uplink_throughput_bps=0,pop_ping_latency_ms=0,pop_ping_drop_rate=0,power_in_watts=0 1700000000\n"
+	if !strings.HasPrefix(requests[0], want) {
+		t.Errorf("Expected first line\n%sgot\n%s", want, requests[0])
+	}
+	if len(w.lines) != 0 {
+		t.Errorf("Expected buffer to be drained, got %d lines", len(w.lines))
+	}
+}
+
+func TestWriter_RetriesAndDrops(t *testing.T) {
+	code := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	w := newTestWriter(t, srv.URL)
+	w.AddSamples(samples(3))
+	w.flush(context.Background())
+	if len(w.lines) != 3 {
+		t.Fatalf("Expected lines to stay buffered after a 503, got %d", len(w.lines))
+	}
+
+	code = http.StatusBadRequest
+	w.flush(context.Background())
+	if len(w.lines) != 0 {
+		t.Errorf("Expected rejected lines to be dropped, got %d", len(w.lines))
+	}
+}
+
+func TestWriter_BufferLimit(t *testing.T) {
+	w := newTestWriter(t, "http://127.0.0.1:1")
+	w.cfg.MaxBuffered = 4
+	w.AddSamples(samples(6))
+
+	if len(w.lines) != 4 || !strings.HasSuffix(w.lines[0], " 1700000002") {
+		t.Errorf("Expected the 4 newest lines, got %v", w.lines)
+	}
+}
@@ -0,0 +1,96 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/R167/starlink_exporter/internal/client"
+)
+
+// entity is one Home Assistant entity announced through discovery
+type entity struct {
+	component string // sensor or binary_sensor
+	object    string // Object ID, unique per device
+	config    map[string]any
+}
+
+// entities returns the Home Assistant entities for the dish
+func (p *Publisher) entities() []entity {
+	sensor := func(object, name, template, unit, deviceClass string) entity {
+		cfg := map[string]any{
+			"name":           name,
+			"value_template": template,
+			"state_class":    "measurement",
+		}
+		if unit != "" {
+			cfg["unit_of_measurement"] = unit
+		}
+		if deviceClass != "" {
+			cfg["device_class"] = deviceClass
+		}
+		return entity{"sensor", object, cfg}
+	}
+
+	entities := []entity{
+		sensor("downlink_throughput", "Download throughput", "{{ (value_json.downlink_throughput_bps / 1000000) | round(2) }}", "Mbit/s", "data_rate"),
+		sensor("uplink_throughput", "Upload throughput", "{{ (value_json.uplink_throughput_bps / 1000000) | round(2) }}", "Mbit/s", "data_rate"),
+		sensor("pop_ping_latency", "Latency", "{{ value_json.pop_ping_latency_ms | round(1) }}", "ms", "duration"),
+		sensor("pop_ping_drop_rate", "Ping drop rate", "{{ (value_json.pop_ping_drop_rate * 100) | round(2) }}", "%", ""),
+		sensor("fraction_obstructed", "Obstruction", "{{ (value_json.fraction_obstructed * 100) | round(2) }}", "%", ""),
+		sensor("power_in", "Power", "{{ value_json.power_in_watts | round(1) }}", "W", "power"),
+		sensor("alert_count", "Active alerts", "{{ value_json.alert_count }}", "", ""),
+		{"sensor", "uptime", map[string]any{
+			"name":                "Uptime",
+			"value_template":      "{{ value_json.uptime_s }}",
+			"unit_of_measurement": "s",
+			"device_class":        "duration",
+			"entity_category":     "diagnostic",
+		}},
+		// Reads the availability topic itself, so it shows off rather than
+		// unavailable when the dish drops
+		{"binary_sensor", "online", map[string]any{
+			"name":         "Online",
+			"state_topic":  p.availabilityTopic,
+			"payload_on":   payloadOnline,
+			"payload_off":  payloadOffline,
+			"device_class": "connectivity",
+		}},
+	}
+
+	for _, alert := range client.AlertNames() {
+		entities = append(entities, entity{"binary_sensor", "alert_" + alert, map[string]any{
+			"name":            "Alert " + strings.ReplaceAll(alert, "_", " "),
+			"value_template":  fmt.Sprintf("{{ 'ON' if value_json.alerts.%s else 'OFF' }}", alert),
+			"device_class":    "problem",
+			"entity_category": "diagnostic",
+		}})
+	}
+	return entities
+}
+
+// discoveryMessages returns the retained discovery config for each entity,
+// keyed by topic
+func (p *Publisher) discoveryMessages(device client.DeviceInfo) map[string]map[string]any {
+	dev := map[string]any{
+		"identifiers":  []string{"starlink_" + device.ID},
+		"name":         "Starlink " + p.cfg.NodeID,
+		"manufacturer": "SpaceX",
+		"model":        device.HardwareVersion,
+		"sw_version":   device.SoftwareVersion,
+	}
+
+	messages := make(map[string]map[string]any)
+	for _, e := range p.entities() {
+		cfg := e.config
+		cfg["unique_id"] = fmt.Sprintf("starlink_%s_%s", device.ID, e.object)
+		cfg["object_id"] = fmt.Sprintf("starlink_%s_%s", p.cfg.NodeID, e.object)
+		cfg["device"] = dev
+		if _, ok := cfg["state_topic"]; !ok {
+			cfg["state_topic"] = p.stateTopic
+			cfg["availability_topic"] = p.availabilityTopic
+		}
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", p.cfg.DiscoveryPrefix, e.component, p.cfg.NodeID, e.object)
+		messages[topic] = cfg
+	}
+	return messages
+}
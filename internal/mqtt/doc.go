@@ -0,0 +1,8 @@
+// Package mqtt publishes dish state to an MQTT broker for users without
+// Prometheus, with Home Assistant discovery so the dish shows up as a device.
+//
+// Every interval the publisher fetches the dish status and publishes a JSON
+// state message. The availability topic follows starlink_up: "online" while the
+// dish answers, "offline" when it doesn't or the exporter goes away (via the
+// MQTT last will).
+package mqtt
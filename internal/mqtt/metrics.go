@@ -0,0 +1,25 @@
+package mqtt
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// MQTT publisher self-metrics. They are package-level so counts survive config
+// reloads that replace the publisher; register them once with MustRegisterMetrics.
+var (
+	messagesPublished = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "starlink_exporter_mqtt_messages_published_total",
+		Help: "Total MQTT messages published (state, availability and discovery)",
+	})
+	publishFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "starlink_exporter_mqtt_publish_failures_total",
+		Help: "Total MQTT messages that failed to publish",
+	})
+	connected = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "starlink_exporter_mqtt_connected",
+		Help: "Whether the exporter is connected to the MQTT broker (1 = connected)",
+	})
+)
+
+// MustRegisterMetrics registers the MQTT publisher self-metrics with reg
+func MustRegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(messagesPublished, publishFailures, connected)
+}
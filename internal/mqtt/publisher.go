@@ -0,0 +1,359 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+	"github.com/R167/starlink_exporter/internal/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Availability payloads, Home Assistant's defaults
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// publishTimeout bounds how long a publish waits for the broker
+const publishTimeout = 10 * time.Second
+
+// state is the JSON message published to the state topic. Values the dish
+// doesn't report (or reports as NaN) are omitted.
+type state struct {
+	DownlinkThroughputBps *float64        `json:"downlink_throughput_bps,omitempty"`
+	UplinkThroughputBps   *float64        `json:"uplink_throughput_bps,omitempty"`
+	PopPingLatencyMs      *float64        `json:"pop_ping_latency_ms,omitempty"`
+	PopPingDropRate       *float64        `json:"pop_ping_drop_rate,omitempty"` // From the latest history sample
+	PowerInWatts          *float64        `json:"power_in_watts,omitempty"`     // From the latest history sample
+	FractionObstructed    *float64        `json:"fraction_obstructed,omitempty"`
+	UptimeS               uint64          `json:"uptime_s"`
+	GPSSats               int             `json:"gps_sats"`
+	SoftwareVersion       string          `json:"software_version"`
+	Alerts                map[string]bool `json:"alerts"`
+	AlertCount            int             `json:"alert_count"`
+}
+
+// Publisher publishes dish state, availability and Home Assistant discovery
+// configs to an MQTT broker
+type Publisher struct {
+	cfg               config.MQTT
+	status            client.Client
+	logger            *slog.Logger
+	client            paho.Client
+	stateTopic        string
+	availabilityTopic string
+
+	// Only touched by the Start goroutine
+	device    *client.DeviceInfo // Last device announced through discovery
+	online    bool
+	published bool // Whether availability has been published on this connection
+	failing   bool // Whether the last publish failed, to log once per outage
+
+	mu       sync.Mutex
+	sample   *collector.HistorySample // Latest history sample
+	announce bool                     // Discovery must be (re)published
+
+	wake      chan struct{} // Publish now instead of waiting for the next tick
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewPublisher creates a publisher for the dish behind status. Nothing connects
+// until Start.
+func NewPublisher(cfg config.MQTT, status client.Client, logger *slog.Logger) *Publisher {
+	base := strings.TrimSuffix(cfg.TopicPrefix, "/") + "/" + cfg.NodeID
+	p := &Publisher{
+		cfg:               cfg,
+		status:            status,
+		logger:            logger.With("component", "mqtt"),
+		stateTopic:        base + "/state",
+		availabilityTopic: base + "/availability",
+		wake:              make(chan struct{}, 1),
+		stopCh:            make(chan struct{}),
+		stoppedCh:         make(chan struct{}),
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "starlink_exporter_" + cfg.NodeID
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(clientID).
+		SetWill(p.availabilityTopic, payloadOffline, cfg.QoS, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10 * time.Second).
+		SetOrderMatters(false).
+		SetOnConnectHandler(p.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			connected.Set(0)
+			p.logger.Warn("Lost connection to MQTT broker", "error", err)
+		})
+	if cfg.Username != "" {
+		opts.SetCredentialsProvider(p.credentials)
+	}
+	p.client = paho.NewClient(opts)
+	return p
+}
+
+// AddSamples records the latest history sample for the power and drop rate
+// fields. It is a collector.SampleHandler and never blocks.
+func (p *Publisher) AddSamples(samples []collector.HistorySample) {
+	latest := samples[len(samples)-1]
+	p.mu.Lock()
+	p.sample = &latest
+	p.mu.Unlock()
+}
+
+// Start connects to the broker and publishes every interval until ctx is
+// cancelled or Stop is called
+func (p *Publisher) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	defer close(p.stoppedCh)
+
+	p.logger.Info("MQTT publisher started", "broker", p.cfg.Broker, "state_topic", p.stateTopic)
+	// Retries in the background until the broker is reachable
+	p.client.Connect()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.disconnect()
+			return
+		case <-p.stopCh:
+			p.disconnect()
+			return
+		case <-p.wake:
+			p.update()
+		case <-ticker.C:
+			p.update()
+		}
+	}
+}
+
+// Stop publishes offline availability and disconnects (safe to call multiple times)
+func (p *Publisher) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+	<-p.stoppedCh
+}
+
+// disconnect marks the dish offline and closes the connection. A clean
+// disconnect doesn't trigger the last will, so offline is published explicitly.
+func (p *Publisher) disconnect() {
+	p.logger.Info("MQTT publisher stopping")
+	if p.client.IsConnectionOpen() {
+		// Best effort; the last will covers a broker that doesn't answer
+		p.client.Publish(p.availabilityTopic, p.cfg.QoS, true, payloadOffline).WaitTimeout(time.Second)
+	}
+	p.client.Disconnect(250)
+	connected.Set(0)
+}
+
+// onConnect runs on every (re)connect. Retained messages may have been lost
+// with the broker, so availability and discovery are published again.
+func (p *Publisher) onConnect(c paho.Client) {
+	connected.Set(1)
+	p.logger.Info("Connected to MQTT broker", "broker", p.cfg.Broker)
+
+	p.mu.Lock()
+	p.announce = true
+	p.mu.Unlock()
+
+	if p.cfg.Discovery {
+		// Home Assistant forgets discovery configs when it restarts without a
+		// persistent broker; its birth message asks for them again
+		c.Subscribe(p.cfg.DiscoveryPrefix+"/status", 0, func(_ paho.Client, msg paho.Message) {
+			if string(msg.Payload()) == payloadOnline {
+				p.mu.Lock()
+				p.announce = true
+				p.mu.Unlock()
+				p.poke()
+			}
+		})
+	}
+	p.poke()
+}
+
+// poke asks the Start goroutine to publish now
+func (p *Publisher) poke() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// update fetches the status and publishes discovery, availability and state
+func (p *Publisher) update() {
+	// IsConnected is also true while paho is still retrying the first connect
+	if !p.client.IsConnectionOpen() {
+		p.published = false
+		return
+	}
+
+	p.mu.Lock()
+	announce := p.announce
+	p.announce = false
+	sample := p.sample
+	p.mu.Unlock()
+	if announce {
+		p.published = false
+	}
+
+	status, err := p.status.GetStatus()
+	online := err == nil
+	if err != nil {
+		p.logger.Debug("Failed to get status", "error", err)
+	}
+
+	if online && p.cfg.Discovery && (announce || p.device == nil || *p.device != status.DeviceInfo) {
+		device := status.DeviceInfo
+		if p.announceDevice(device) == nil {
+			p.device = &device
+		}
+	}
+
+	if !p.published || online != p.online {
+		payload := payloadOffline
+		if online {
+			payload = payloadOnline
+		}
+		if p.publish(p.availabilityTopic, []byte(payload)) == nil {
+			p.published = true
+			p.online = online
+		}
+	}
+
+	if online {
+		data, err := json.Marshal(newState(status, sample))
+		if err != nil {
+			p.logger.Error("Failed to encode state", "error", err)
+			return
+		}
+		p.publish(p.stateTopic, data)
+	}
+}
+
+// announceDevice publishes the Home Assistant discovery configs for device
+func (p *Publisher) announceDevice(device client.DeviceInfo) error {
+	messages := p.discoveryMessages(device)
+	topics := make([]string, 0, len(messages))
+	for topic := range messages {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+
+	var errs []error
+	for _, topic := range topics {
+		data, err := json.Marshal(messages[topic])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := p.publish(topic, data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		p.logger.Info("Published Home Assistant discovery", "device", device.ID, "entities", len(topics))
+	}
+	return errors.Join(errs...)
+}
+
+// publish sends a retained message and waits for the broker to accept it. The
+// wait is cut short by Stop so a broken connection can't hold up a reload.
+func (p *Publisher) publish(topic string, payload []byte) error {
+	token := p.client.Publish(topic, p.cfg.QoS, true, payload)
+	timer := time.NewTimer(publishTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-token.Done():
+		err = token.Error()
+	case <-timer.C:
+		err = fmt.Errorf("timed out publishing to %s", topic)
+	case <-p.stopCh:
+		err = fmt.Errorf("stopped while publishing to %s", topic)
+	}
+
+	if err != nil {
+		publishFailures.Inc()
+		if !p.failing {
+			p.logger.Warn("MQTT publish failed", "topic", topic, "error", err)
+			p.failing = true
+		} else {
+			p.logger.Debug("MQTT publish failed", "topic", topic, "error", err)
+		}
+		return err
+	}
+	messagesPublished.Inc()
+	if p.failing {
+		p.logger.Info("MQTT publish recovered")
+		p.failing = false
+	}
+	return nil
+}
+
+// credentials returns the username and password, re-reading the password file
+// so it can be rotated
+func (p *Publisher) credentials() (string, string) {
+	if p.cfg.PasswordFile == "" {
+		return p.cfg.Username, p.cfg.Password
+	}
+	data, err := os.ReadFile(p.cfg.PasswordFile)
+	if err != nil {
+		p.logger.Error("Failed to read MQTT password file", "error", err)
+		return p.cfg.Username, ""
+	}
+	return p.cfg.Username, strings.TrimSpace(string(data))
+}
+
+// newState builds the state message. sample may be nil.
+func newState(status *client.StatusResponse, sample *collector.HistorySample) state {
+	s := state{
+		DownlinkThroughputBps: finite(status.DownlinkThroughputBps),
+		UplinkThroughputBps:   finite(status.UplinkThroughputBps),
+		PopPingLatencyMs:      finite(status.PopPingLatencyMs),
+		FractionObstructed:    finite(status.ObstructionStats.FractionObstructed),
+		UptimeS:               status.DeviceState.UptimeS,
+		GPSSats:               status.GPSStats.GPSSats,
+		SoftwareVersion:       status.DeviceInfo.SoftwareVersion,
+		Alerts:                make(map[string]bool),
+		AlertCount:            len(status.Alerts),
+	}
+	if sample != nil {
+		s.PopPingDropRate = finite(sample.PopPingDropRate)
+		s.PowerInWatts = finite(sample.PowerInWatts)
+	}
+	for _, name := range client.AlertNames() {
+		s.Alerts[name] = false
+	}
+	for _, name := range status.Alerts {
+		s.Alerts[name] = true
+	}
+	return s
+}
+
+// finite returns a pointer to v, or nil if it can't be encoded as JSON
+func finite(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
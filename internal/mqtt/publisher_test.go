@@ -0,0 +1,114 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/collector"
+	"github.com/R167/starlink_exporter/internal/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeBroker records published messages. Methods the publisher doesn't use
+// under test panic through the nil embedded interface.
+type fakeBroker struct {
+	paho.Client
+	messages map[string]string
+	order    []string
+}
+
+func (f *fakeBroker) IsConnectionOpen() bool { return true }
+
+func (f *fakeBroker) Publish(topic string, qos byte, retained bool, payload any) paho.Token {
+	f.messages[topic] = string(payload.([]byte))
+	f.order = append(f.order, topic)
+	return doneToken{}
+}
+
+// doneToken is a token that has already completed successfully
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Done() <-chan struct{}          { ch := make(chan struct{}); close(ch); return ch }
+func (doneToken) Error() error                   { return nil }
+
+// fakeStatus returns status, or err if it is set
+type fakeStatus struct {
+	status *client.StatusResponse
+	err    error
+}
+
+func (f *fakeStatus) GetStatus() (*client.StatusResponse, error) { return f.status, f.err }
+
+func (f *fakeStatus) GetHistory() (*client.HistoryResponse, error) { return nil, nil }
+
+func newTestPublisher(status client.Client) (*Publisher, *fakeBroker) {
+	cfg := config.Default().MQTT
+	cfg.Broker = "tcp://127.0.0.1:1"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	p := NewPublisher(cfg, status, logger)
+	broker := &fakeBroker{messages: make(map[string]string)}
+	p.client = broker
+	return p, broker
+}
+
+func TestPublisher_Update(t *testing.T) {
+	status := &fakeStatus{status: &client.StatusResponse{
+		DeviceInfo:            client.DeviceInfo{ID: "ut01", HardwareVersion: "rev4_prod1"},
+		DownlinkThroughputBps: 1e6,
+		Alerts:                []string{"thermal_throttle"},
+	}}
+	p, broker := newTestPublisher(status)
+	p.AddSamples([]collector.HistorySample{{PowerInWatts: 40}, {PowerInWatts: 42}})
+	p.update()
+
+	if got := broker.messages["starlink/dish/availability"]; got != "online" {
+		t.Errorf("Expected availability online, got %q", got)
+	}
+
+	var state map[string]any
+	if err := json.Unmarshal([]byte(broker.messages["starlink/dish/state"]), &state); err != nil {
+		t.Fatalf("Invalid state: %v", err)
+	}
+	if state["power_in_watts"] != 42.0 || state["alert_count"] != 1.0 {
+		t.Errorf("Expected latest power and one alert, got %v", state)
+	}
+	if alerts := state["alerts"].(map[string]any); alerts["thermal_throttle"] != true || alerts["motors_stuck"] != false {
+		t.Errorf("Expected every alert with thermal_throttle set, got %v", alerts)
+	}
+
+	var cfg map[string]any
+	if err := json.Unmarshal([]byte(broker.messages["homeassistant/sensor/dish/power_in/config"]), &cfg); err != nil {
+		t.Fatalf("Missing power discovery config: %v", err)
+	}
+	if cfg["unique_id"] != "starlink_ut01_power_in" || cfg["state_topic"] != "starlink/dish/state" {
+		t.Errorf("Unexpected discovery config %v", cfg)
+	}
+	if !strings.HasPrefix(broker.order[0], "homeassistant/") {
+		t.Errorf("Expected discovery before state, got %v first", broker.order[0])
+	}
+
+	// Discovery is only published again when the device changes or on request
+	broker.order = nil
+	p.update()
+	if len(broker.order) != 1 || broker.order[0] != "starlink/dish/state" {
+		t.Errorf("Expected only a state update, got %v", broker.order)
+	}
+
+	// The dish going away flips availability once and stops state updates
+	status.err = errors.New("unreachable")
+	broker.order = nil
+	p.update()
+	p.update()
+	if len(broker.order) != 1 || broker.messages["starlink/dish/availability"] != "offline" {
+		t.Errorf("Expected a single offline message, got %v", broker.order)
+	}
+}
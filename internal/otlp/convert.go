@@ -0,0 +1,172 @@
+package otlp
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// units maps Prometheus base unit suffixes to UCUM units
+var units = map[string]string{
+	"seconds":  "s",
+	"ms":       "ms",
+	"bytes":    "By",
+	"bps":      "bit/s",
+	"mbps":     "Mbit/s",
+	"joules":   "J",
+	"watts":    "W",
+	"ratio":    "1",
+	"fraction": "1",
+	"dbm":      "dBm",
+	"celsius":  "Cel",
+}
+
+// Convert turns gathered metric families into OTLP metrics. Cumulative values
+// without a created timestamp use start as their start time.
+func Convert(families []*dto.MetricFamily, start, now time.Time) []*metricspb.Metric {
+	startNano := uint64(start.UnixNano())
+	nowNano := uint64(now.UnixNano())
+
+	metrics := make([]*metricspb.Metric, 0, len(families))
+	for _, mf := range families {
+		m := &metricspb.Metric{
+			Name:        mf.GetName(),
+			Description: mf.GetHelp(),
+			Unit:        unit(mf.GetName()),
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}
+			for _, pm := range mf.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, &metricspb.NumberDataPoint{
+					Attributes:        attributes(pm),
+					StartTimeUnixNano: startTime(pm.GetCounter().GetCreatedTimestamp().AsTime(), pm.GetCounter().CreatedTimestamp != nil, startNano),
+					TimeUnixNano:      timestamp(pm, nowNano),
+					Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: pm.GetCounter().GetValue()},
+				})
+			}
+			m.Data = &metricspb.Metric_Sum{Sum: sum}
+
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := &metricspb.Gauge{}
+			for _, pm := range mf.GetMetric() {
+				value := pm.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = pm.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+					Attributes:   attributes(pm),
+					TimeUnixNano: timestamp(pm, nowNano),
+					Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+				})
+			}
+			m.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+
+		case dto.MetricType_HISTOGRAM:
+			hist := &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}
+			for _, pm := range mf.GetMetric() {
+				h := pm.GetHistogram()
+				dp := &metricspb.HistogramDataPoint{
+					Attributes:        attributes(pm),
+					StartTimeUnixNano: startTime(h.GetCreatedTimestamp().AsTime(), h.CreatedTimestamp != nil, startNano),
+					TimeUnixNano:      timestamp(pm, nowNano),
+					Count:             h.GetSampleCount(),
+					Sum:               h.SampleSum,
+				}
+				// Prometheus buckets are cumulative; OTLP counts each bucket
+				// separately, with an implicit +Inf bucket at the end
+				var prev uint64
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), 1) {
+						continue
+					}
+					dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+					dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-prev)
+					prev = b.GetCumulativeCount()
+				}
+				dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-prev)
+				hist.DataPoints = append(hist.DataPoints, dp)
+			}
+			m.Data = &metricspb.Metric_Histogram{Histogram: hist}
+
+		case dto.MetricType_SUMMARY:
+			summary := &metricspb.Summary{}
+			for _, pm := range mf.GetMetric() {
+				s := pm.GetSummary()
+				dp := &metricspb.SummaryDataPoint{
+					Attributes:        attributes(pm),
+					StartTimeUnixNano: startTime(s.GetCreatedTimestamp().AsTime(), s.CreatedTimestamp != nil, startNano),
+					TimeUnixNano:      timestamp(pm, nowNano),
+					Count:             s.GetSampleCount(),
+					Sum:               s.GetSampleSum(),
+				}
+				for _, q := range s.GetQuantile() {
+					dp.QuantileValues = append(dp.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+						Quantile: q.GetQuantile(),
+						Value:    q.GetValue(),
+					})
+				}
+				summary.DataPoints = append(summary.DataPoints, dp)
+			}
+			m.Data = &metricspb.Metric_Summary{Summary: summary}
+
+		default:
+			// Gauge histograms aren't produced by this exporter
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// attributes converts a metric's labels into OTLP attributes
+func attributes(m *dto.Metric) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		attrs = append(attrs, stringAttribute(lp.GetName(), lp.GetValue()))
+	}
+	return attrs
+}
+
+// stringAttribute returns a string-valued attribute
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+// timestamp returns the metric's own timestamp if it has one, otherwise now
+func timestamp(m *dto.Metric, now uint64) uint64 {
+	if m.TimestampMs != nil {
+		return uint64(m.GetTimestampMs()) * uint64(time.Millisecond)
+	}
+	return now
+}
+
+// startTime returns created if it is set, otherwise fallback
+func startTime(created time.Time, ok bool, fallback uint64) uint64 {
+	if !ok {
+		return fallback
+	}
+	return uint64(created.UnixNano())
+}
+
+// unit derives a UCUM unit from a metric name's base unit suffix
+func unit(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	if i := strings.LastIndexByte(name, '_'); i >= 0 {
+		return units[name[i+1:]]
+	}
+	return ""
+}
@@ -0,0 +1,52 @@
+package otlp
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func TestConvert(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_download_bytes_total", Help: "h"})
+	counter.Add(42)
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_duration_seconds", Help: "h", Buckets: []float64{1, 2}})
+	hist.Observe(0.5)
+	hist.Observe(1.5)
+	hist.Observe(5)
+	reg.MustRegister(counter, hist)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	start := time.Unix(100, 0)
+	metrics := Convert(families, start, time.Unix(200, 0))
+	if len(metrics) != 2 {
+		t.Fatalf("Expected 2 metrics, got %d", len(metrics))
+	}
+
+	sum := metrics[0].GetSum()
+	if metrics[0].GetName() != "test_download_bytes_total" || metrics[0].GetUnit() != "By" || sum == nil {
+		t.Fatalf("Expected a By sum named test_download_bytes_total, got %v", metrics[0])
+	}
+	if !sum.GetIsMonotonic() || sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Errorf("Expected a cumulative monotonic sum, got %v", sum)
+	}
+	dp := sum.GetDataPoints()[0]
+	// client_golang counters carry their creation time
+	if dp.GetAsDouble() != 42 || dp.GetStartTimeUnixNano() == uint64(start.UnixNano()) || dp.GetStartTimeUnixNano() == 0 {
+		t.Errorf("Unexpected data point %v", dp)
+	}
+
+	h := metrics[1].GetHistogram().GetDataPoints()[0]
+	if !slices.Equal(h.GetExplicitBounds(), []float64{1, 2}) || !slices.Equal(h.GetBucketCounts(), []uint64{1, 1, 1}) {
+		t.Errorf("Expected per-bucket counts [1 1 1] for bounds [1 2], got %v for %v", h.GetBucketCounts(), h.GetExplicitBounds())
+	}
+	if h.GetCount() != 3 || h.GetSum() != 7 {
+		t.Errorf("Expected count 3 and sum 7, got %d and %v", h.GetCount(), h.GetSum())
+	}
+}
@@ -0,0 +1,11 @@
+// Package otlp pushes the exporter's metrics to an OpenTelemetry collector over
+// OTLP/gRPC or OTLP/HTTP.
+//
+// On every interval the registry is gathered and converted: counters become
+// cumulative monotonic sums, gauges become gauges, and histograms and summaries
+// keep their shape. Metric names are left as they appear on /metrics. The dish's
+// DeviceInfo is attached as resource attributes once it is known.
+//
+// Because every value is cumulative, a failed export isn't queued; the next
+// successful one carries the totals forward.
+package otlp
@@ -0,0 +1,241 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// scopeName identifies the exporter as the instrumentation scope of its metrics
+const scopeName = "github.com/R167/starlink_exporter"
+
+// Exporter periodically gathers metrics and exports them to an OTLP endpoint
+type Exporter struct {
+	cfg       config.OTLP
+	gatherer  prometheus.Gatherer
+	status    client.Client // Source of DeviceInfo; nil without a dish target
+	startTime time.Time     // Start time of cumulative values
+	logger    *slog.Logger
+	failing   bool               // Whether the last export failed, to log once per outage
+	device    *client.DeviceInfo // Cached once a status fetch succeeds
+
+	grpcConn   *grpc.ClientConn // Set for the grpc protocol
+	grpcClient colmetricspb.MetricsServiceClient
+	httpClient *http.Client // Set for the http protocol
+	url        string
+
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewExporter creates an exporter pushing what gatherer returns. The dish
+// status client provides resource attributes and may be nil.
+func NewExporter(cfg config.OTLP, gatherer prometheus.Gatherer, status client.Client, startTime time.Time, logger *slog.Logger) (*Exporter, error) {
+	e := &Exporter{
+		cfg:       cfg,
+		gatherer:  gatherer,
+		status:    status,
+		startTime: startTime,
+		logger:    logger.With("component", "otlp"),
+		stopCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}
+
+	switch cfg.Protocol {
+	case "grpc":
+		creds := credentials.NewTLS(&tls.Config{})
+		if cfg.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(cfg.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP client: %v", err)
+		}
+		e.grpcConn = conn
+		e.grpcClient = colmetricspb.NewMetricsServiceClient(conn)
+	case "http":
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP endpoint: %v", err)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/metrics"
+		}
+		e.url = u.String()
+		e.httpClient = &http.Client{Timeout: cfg.Timeout}
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", cfg.Protocol)
+	}
+	return e, nil
+}
+
+// Start exports metrics every interval until ctx is cancelled or Stop is called
+func (e *Exporter) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	defer close(e.stoppedCh)
+
+	e.logger.Info("OTLP export started", "endpoint", e.cfg.Endpoint, "protocol", e.cfg.Protocol, "interval", e.cfg.Interval)
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.logger.Info("OTLP export stopping")
+			return
+		case <-e.stopCh:
+			e.logger.Info("OTLP export stopping")
+			return
+		case <-ticker.C:
+			e.export(ctx)
+		}
+	}
+}
+
+// Stop stops the exporter (safe to call multiple times)
+func (e *Exporter) Stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopCh)
+	})
+	<-e.stoppedCh
+}
+
+// Close closes the gRPC connection
+func (e *Exporter) Close() error {
+	if e.grpcConn != nil {
+		return e.grpcConn.Close()
+	}
+	return nil
+}
+
+// export gathers and sends one request, logging failures once per outage
+func (e *Exporter) export(ctx context.Context) {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		// Gather returns whatever it could collect alongside the error
+		e.logger.Debug("Failed to gather some metrics", "error", err)
+	}
+
+	req := e.request(Convert(families, e.startTime, time.Now()))
+	if err := e.send(ctx, req); err != nil {
+		exportsTotal.WithLabelValues("failure").Inc()
+		if !e.failing {
+			e.logger.Warn("OTLP export failed", "error", err)
+			e.failing = true
+		} else {
+			e.logger.Debug("OTLP export failed", "error", err)
+		}
+		return
+	}
+
+	exportsTotal.WithLabelValues("success").Inc()
+	lastSuccess.SetToCurrentTime()
+	if e.failing {
+		e.logger.Info("OTLP export recovered")
+		e.failing = false
+	}
+}
+
+// request wraps metrics in an export request with the exporter's resource
+func (e *Exporter) request(metrics []*metricspb.Metric) *colmetricspb.ExportMetricsServiceRequest {
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: e.resource()},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: scopeName},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+// resource returns the resource attributes, fetching the dish's DeviceInfo until
+// it is known. Until then only the service attributes are sent.
+func (e *Exporter) resource() []*commonpb.KeyValue {
+	attrs := []*commonpb.KeyValue{stringAttribute("service.name", "starlink_exporter")}
+
+	if e.device == nil && e.status != nil {
+		if status, err := e.status.GetStatus(); err == nil {
+			e.device = &status.DeviceInfo
+		} else {
+			e.logger.Debug("Failed to fetch device info for resource attributes", "error", err)
+		}
+	}
+	if e.device != nil {
+		attrs = append(attrs,
+			stringAttribute("service.instance.id", e.device.ID),
+			stringAttribute("device.id", e.device.ID),
+			stringAttribute("device.manufacturer", "SpaceX"),
+			stringAttribute("device.model.identifier", e.device.HardwareVersion),
+			stringAttribute("starlink.software_version", e.device.SoftwareVersion),
+			stringAttribute("starlink.country_code", e.device.CountryCode),
+		)
+	}
+	return attrs
+}
+
+// send exports req over the configured protocol
+func (e *Exporter) send(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+	defer cancel()
+
+	if e.grpcClient != nil {
+		if len(e.cfg.Headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.cfg.Headers))
+		}
+		resp, err := e.grpcClient.Export(ctx, req)
+		if err != nil {
+			return err
+		}
+		if ps := resp.GetPartialSuccess(); ps.GetRejectedDataPoints() > 0 {
+			e.logger.Debug("OTLP endpoint rejected some data points", "rejected", ps.GetRejectedDataPoints(), "message", ps.GetErrorMessage())
+		}
+		return nil
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %v", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "starlink_exporter")
+	for k, v := range e.cfg.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := e.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
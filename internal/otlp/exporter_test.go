@@ -0,0 +1,136 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/client"
+	"github.com/R167/starlink_exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeCollector records the requests exported to it
+type fakeCollector struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	requests chan *colmetricspb.ExportMetricsServiceRequest
+	headers  chan metadata.MD
+}
+
+func (f *fakeCollector) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.headers <- md
+	f.requests <- req
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+// fakeStatus returns a fixed status
+type fakeStatus struct{}
+
+func (fakeStatus) GetStatus() (*client.StatusResponse, error) {
+	return &client.StatusResponse{DeviceInfo: client.DeviceInfo{ID: "ut01", HardwareVersion: "rev4_prod1"}}, nil
+}
+
+func (fakeStatus) GetHistory() (*client.HistoryResponse, error) {
+	return nil, nil
+}
+
+func TestExporter_HTTP(t *testing.T) {
+	var path, auth string
+	var got colmetricspb.ExportMetricsServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		if err := proto.Unmarshal(body, &got); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "starlink_up", Help: "h"})
+	gauge.Set(1)
+	reg.MustRegister(gauge)
+
+	cfg := config.Default().OTLP
+	cfg.Protocol = "http"
+	cfg.Endpoint = srv.URL
+	cfg.Headers = map[string]string{"Authorization": "Bearer secret"}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	e, err := NewExporter(cfg, reg, fakeStatus{}, time.Now(), logger)
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	defer e.Close()
+	e.export(context.Background())
+
+	if path != "/v1/metrics" || auth != "Bearer secret" {
+		t.Errorf("Expected POST to /v1/metrics with auth header, got %q %q", path, auth)
+	}
+	if len(got.GetResourceMetrics()) != 1 {
+		t.Fatalf("Expected one resource, got %v", &got)
+	}
+	rm := got.GetResourceMetrics()[0]
+	attrs := map[string]string{}
+	for _, kv := range rm.GetResource().GetAttributes() {
+		attrs[kv.GetKey()] = kv.GetValue().GetStringValue()
+	}
+	if attrs["device.id"] != "ut01" || attrs["device.model.identifier"] != "rev4_prod1" {
+		t.Errorf("Expected DeviceInfo resource attributes, got %v", attrs)
+	}
+	if m := rm.GetScopeMetrics()[0].GetMetrics(); len(m) != 1 || m[0].GetGauge().GetDataPoints()[0].GetAsDouble() != 1 {
+		t.Errorf("Expected starlink_up gauge, got %v", m)
+	}
+}
+
+func TestExporter_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	fake := &fakeCollector{
+		requests: make(chan *colmetricspb.ExportMetricsServiceRequest, 1),
+		headers:  make(chan metadata.MD, 1),
+	}
+	srv := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(srv, fake)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cfg := config.Default().OTLP
+	cfg.Endpoint = lis.Addr().String()
+	cfg.Insecure = true
+	cfg.Headers = map[string]string{"x-scope-orgid": "cabin"}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	e, err := NewExporter(cfg, prometheus.NewRegistry(), nil, time.Now(), logger)
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	defer e.Close()
+	e.export(context.Background())
+
+	select {
+	case md := <-fake.headers:
+		if got := md.Get("x-scope-orgid"); len(got) != 1 || got[0] != "cabin" {
+			t.Errorf("Expected x-scope-orgid header, got %v", md)
+		}
+		req := <-fake.requests
+		if name := req.GetResourceMetrics()[0].GetResource().GetAttributes()[0].GetValue().GetStringValue(); name != "starlink_exporter" {
+			t.Errorf("Expected service.name starlink_exporter, got %q", name)
+		}
+	default:
+		t.Fatal("Expected an export request")
+	}
+}
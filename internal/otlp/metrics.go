@@ -0,0 +1,24 @@
+package otlp
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// OTLP export self-metrics. They are package-level so counts survive config
+// reloads that replace the exporter; register them once with MustRegisterMetrics.
+var (
+	exportsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_otlp_exports_total",
+			Help: "Total OTLP metric exports by result (success, failure)",
+		},
+		[]string{"result"},
+	)
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "starlink_exporter_otlp_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last export accepted by the OTLP endpoint (0 = never)",
+	})
+)
+
+// MustRegisterMetrics registers the OTLP export self-metrics with reg
+func MustRegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(exportsTotal, lastSuccess)
+}
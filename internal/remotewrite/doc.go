@@ -0,0 +1,9 @@
+// Package remotewrite pushes the exporter's metrics to a Prometheus remote_write
+// endpoint, for dishes behind CGNAT where Prometheus can't scrape the exporter.
+//
+// On every interval the registry is gathered, encoded as a snappy-compressed
+// remote_write WriteRequest, and appended to a bounded queue. The queue is sent
+// oldest first; batches that fail with a retryable error stay queued until the
+// endpoint is reachable again. With a queue directory configured, the queue is
+// kept on disk so it survives restarts during long uplink outages.
+package remotewrite
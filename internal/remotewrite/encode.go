@@ -0,0 +1,126 @@
+package remotewrite
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers from the remote_write protocol (prompb/remote.proto, types.proto)
+const (
+	writeRequestTimeseries = 1 // WriteRequest.timeseries
+	timeSeriesLabels       = 1 // TimeSeries.labels
+	timeSeriesSamples      = 2 // TimeSeries.samples
+	labelName              = 1 // Label.name
+	labelValue             = 2 // Label.value
+	sampleValue            = 1 // Sample.value
+	sampleTimestamp        = 2 // Sample.timestamp
+)
+
+// label is one name/value pair of a series
+type label struct {
+	name, value string
+}
+
+// Encode converts gathered metric families into a serialized remote_write
+// WriteRequest. Samples without their own timestamp are stamped with ts.
+// Summaries and histograms are flattened into the same series the text format
+// exposes (quantiles or _bucket, plus _sum and _count).
+func Encode(families []*dto.MetricFamily, ts time.Time) []byte {
+	var buf []byte
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			labels := make([]label, 0, len(m.GetLabel())+2)
+			for _, lp := range m.GetLabel() {
+				labels = append(labels, label{lp.GetName(), lp.GetValue()})
+			}
+			tsMs := ts.UnixMilli()
+			if m.TimestampMs != nil {
+				tsMs = m.GetTimestampMs()
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				buf = appendSeries(buf, name, labels, m.GetCounter().GetValue(), tsMs)
+			case dto.MetricType_GAUGE:
+				buf = appendSeries(buf, name, labels, m.GetGauge().GetValue(), tsMs)
+			case dto.MetricType_UNTYPED:
+				buf = appendSeries(buf, name, labels, m.GetUntyped().GetValue(), tsMs)
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					buf = appendSeries(buf, name, withLabel(labels, "quantile", formatFloat(q.GetQuantile())), q.GetValue(), tsMs)
+				}
+				buf = appendSeries(buf, name+"_sum", labels, s.GetSampleSum(), tsMs)
+				buf = appendSeries(buf, name+"_count", labels, float64(s.GetSampleCount()), tsMs)
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				sawInf := false
+				for _, b := range h.GetBucket() {
+					sawInf = sawInf || math.IsInf(b.GetUpperBound(), 1)
+					buf = appendSeries(buf, name+"_bucket", withLabel(labels, "le", formatFloat(b.GetUpperBound())), float64(b.GetCumulativeCount()), tsMs)
+				}
+				if !sawInf {
+					buf = appendSeries(buf, name+"_bucket", withLabel(labels, "le", "+Inf"), float64(h.GetSampleCount()), tsMs)
+				}
+				buf = appendSeries(buf, name+"_sum", labels, h.GetSampleSum(), tsMs)
+				buf = appendSeries(buf, name+"_count", labels, float64(h.GetSampleCount()), tsMs)
+			}
+		}
+	}
+	return buf
+}
+
+// withLabel returns a copy of labels with one more label appended
+func withLabel(labels []label, name, value string) []label {
+	return append(slices.Clip(labels), label{name, value})
+}
+
+// appendSeries appends a WriteRequest.timeseries entry with a single sample.
+// Labels are sorted by name as remote_write requires.
+func appendSeries(buf []byte, name string, labels []label, value float64, tsMs int64) []byte {
+	all := append([]label{{"__name__", name}}, labels...)
+	slices.SortFunc(all, func(a, b label) int { return strings.Compare(a.name, b.name) })
+
+	var series []byte
+	for _, l := range all {
+		var lb []byte
+		lb = protowire.AppendTag(lb, labelName, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, labelValue, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+
+		series = protowire.AppendTag(series, timeSeriesLabels, protowire.BytesType)
+		series = protowire.AppendBytes(series, lb)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, sampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, sampleTimestamp, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(tsMs))
+
+	series = protowire.AppendTag(series, timeSeriesSamples, protowire.BytesType)
+	series = protowire.AppendBytes(series, sample)
+
+	buf = protowire.AppendTag(buf, writeRequestTimeseries, protowire.BytesType)
+	return protowire.AppendBytes(buf, series)
+}
+
+// formatFloat formats a quantile or bucket bound the way the text format does
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
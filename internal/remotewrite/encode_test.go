@@ -0,0 +1,79 @@
+package remotewrite
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeSeries decodes a WriteRequest into "labels value@ts" strings
+func decodeSeries(t *testing.T, buf []byte) []string {
+	t.Helper()
+	var out []string
+	for len(buf) > 0 {
+		_, _, n := protowire.ConsumeTag(buf)
+		series, m := protowire.ConsumeBytes(buf[n:])
+		if m < 0 {
+			t.Fatalf("Invalid timeseries: %v", protowire.ParseError(m))
+		}
+		buf = buf[n+m:]
+
+		var labels []string
+		var sample string
+		for len(series) > 0 {
+			num, _, n := protowire.ConsumeTag(series)
+			field, m := protowire.ConsumeBytes(series[n:])
+			series = series[n+m:]
+			switch num {
+			case timeSeriesLabels:
+				_, _, n := protowire.ConsumeTag(field)
+				name, m := protowire.ConsumeString(field[n:])
+				field = field[n+m:]
+				_, _, n = protowire.ConsumeTag(field)
+				value, _ := protowire.ConsumeString(field[n:])
+				labels = append(labels, name+"="+value)
+			case timeSeriesSamples:
+				_, _, n := protowire.ConsumeTag(field)
+				bits, m := protowire.ConsumeFixed64(field[n:])
+				field = field[n+m:]
+				_, _, n = protowire.ConsumeTag(field)
+				ts, _ := protowire.ConsumeVarint(field[n:])
+				sample = fmt.Sprintf("%s@%d", formatFloat(math.Float64frombits(bits)), int64(ts))
+			}
+		}
+		out = append(out, strings.Join(labels, ",")+" "+sample)
+	}
+	return out
+}
+
+func TestEncode(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_gauge", Help: "h"}, []string{"zone", "addr"})
+	gauge.WithLabelValues("b", "a").Set(2.5)
+	hist := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_seconds", Help: "h", Buckets: []float64{1}})
+	hist.Observe(0.5)
+	hist.Observe(3)
+	reg.MustRegister(gauge, hist)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	got := decodeSeries(t, Encode(families, time.UnixMilli(1000)))
+	want := []string{
+		"__name__=test_gauge,addr=a,zone=b 2.5@1000",
+		"__name__=test_seconds_bucket,le=1 1@1000",
+		"__name__=test_seconds_bucket,le=+Inf 2@1000",
+		"__name__=test_seconds_sum 3.5@1000",
+		"__name__=test_seconds_count 2@1000",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected series:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,40 @@
+package remotewrite
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// remote_write self-metrics. They are package-level so counts survive config
+// reloads that replace the writer; register them once with MustRegisterMetrics.
+var (
+	batchesSent = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "starlink_exporter_remote_write_batches_sent_total",
+		Help: "Total batches accepted by the remote_write endpoint",
+	})
+	requestFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "starlink_exporter_remote_write_failed_requests_total",
+		Help: "Total remote_write requests that failed with a retryable error; the batch stays queued",
+	})
+	batchesDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "starlink_exporter_remote_write_batches_dropped_total",
+			Help: "Total batches dropped without being sent by reason (rejected, queue_full, corrupt)",
+		},
+		[]string{"reason"},
+	)
+	queueBatches = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "starlink_exporter_remote_write_queue_batches",
+		Help: "Number of batches waiting to be sent",
+	})
+	queueBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "starlink_exporter_remote_write_queue_bytes",
+		Help: "Size of the batches waiting to be sent in bytes",
+	})
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "starlink_exporter_remote_write_last_success_timestamp_seconds",
+		Help: "Unix timestamp of the last batch accepted by the remote_write endpoint (0 = never)",
+	})
+)
+
+// MustRegisterMetrics registers the remote_write self-metrics with reg
+func MustRegisterMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(batchesSent, requestFailures, batchesDropped, queueBatches, queueBytes, lastSuccess)
+}
@@ -0,0 +1,211 @@
+package remotewrite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// batchSuffix is the file extension of batches in a disk queue
+const batchSuffix = ".batch"
+
+// queue is a FIFO of encoded batches bounded by their total size. When a push
+// takes the queue over its limit, the oldest batches are dropped; the newest is
+// always kept.
+type queue interface {
+	push(batch []byte) (dropped int, err error)
+	peek() ([]byte, error) // nil if the queue is empty
+	pop() error
+	size() (batches int, bytes int64)
+}
+
+// memQueue is a queue held in memory
+type memQueue struct {
+	batches  [][]byte
+	bytes    int64
+	maxBytes int64
+}
+
+func newMemQueue(maxBytes int64) *memQueue {
+	return &memQueue{maxBytes: maxBytes}
+}
+
+func (q *memQueue) push(batch []byte) (int, error) {
+	q.batches = append(q.batches, batch)
+	q.bytes += int64(len(batch))
+	return q.trim(), nil
+}
+
+// trim drops the oldest batches until the queue fits in maxBytes
+func (q *memQueue) trim() int {
+	dropped := 0
+	for q.bytes > q.maxBytes && len(q.batches) > 1 {
+		q.bytes -= int64(len(q.batches[0]))
+		q.batches[0] = nil
+		q.batches = q.batches[1:]
+		dropped++
+	}
+	return dropped
+}
+
+func (q *memQueue) peek() ([]byte, error) {
+	if len(q.batches) == 0 {
+		return nil, nil
+	}
+	return q.batches[0], nil
+}
+
+func (q *memQueue) pop() error {
+	if len(q.batches) > 0 {
+		q.bytes -= int64(len(q.batches[0]))
+		q.batches[0] = nil
+		q.batches = q.batches[1:]
+	}
+	return nil
+}
+
+func (q *memQueue) size() (int, int64) {
+	return len(q.batches), q.bytes
+}
+
+// diskQueue is a queue stored as one file per batch, named by a sequence number
+// so a directory listing gives the send order
+type diskQueue struct {
+	dir      string
+	seqs     []uint64 // Queued batches, oldest first
+	sizes    []int64  // File size of each entry in seqs
+	bytes    int64
+	maxBytes int64
+	next     uint64 // Sequence number of the next push
+}
+
+// openDiskQueue opens the queue in dir, creating the directory if needed and
+// picking up batches left by a previous run
+func openDiskQueue(dir string, maxBytes int64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory: %v", err)
+	}
+
+	q := &diskQueue{dir: dir, maxBytes: maxBytes}
+	if err := q.load(entries); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// reload rescans the directory, picking up batches written by another writer
+// since the queue was opened
+func (q *diskQueue) reload() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read queue directory: %v", err)
+	}
+	return q.load(entries)
+}
+
+// load replaces the queue state with the batches in entries
+func (q *diskQueue) load(entries []os.DirEntry) error {
+	q.seqs, q.sizes, q.bytes, q.next = nil, nil, 0, 1
+	for _, e := range entries {
+		name := e.Name()
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, batchSuffix), 10, 64)
+		if err != nil || !strings.HasSuffix(name, batchSuffix) || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		q.seqs = append(q.seqs, seq)
+		q.sizes = append(q.sizes, info.Size())
+		q.bytes += info.Size()
+		q.next = max(q.next, seq+1)
+	}
+	// ReadDir sorts by name and names are zero padded, so seqs is already in order
+	if !slices.IsSorted(q.seqs) {
+		return fmt.Errorf("queue directory %s has unexpected file names", q.dir)
+	}
+	return nil
+}
+
+// removeTemp deletes batches whose write was interrupted. Another writer may be
+// mid-push into the directory until it is stopped, so this is only safe once
+// the queue is the directory's sole writer.
+func (q *diskQueue) removeTemp() error {
+	tmps, err := filepath.Glob(filepath.Join(q.dir, "*"+batchSuffix+".tmp"))
+	if err != nil {
+		return fmt.Errorf("failed to list queue directory: %v", err)
+	}
+	for _, tmp := range tmps {
+		if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove interrupted batch: %v", err)
+		}
+	}
+	return nil
+}
+
+// path returns the file name of batch seq
+func (q *diskQueue) path(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", seq, batchSuffix))
+}
+
+func (q *diskQueue) push(batch []byte) (int, error) {
+	seq := q.next
+	tmp := q.path(seq) + ".tmp"
+	if err := os.WriteFile(tmp, batch, 0o644); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to write batch: %v", err)
+	}
+	if err := os.Rename(tmp, q.path(seq)); err != nil {
+		os.Remove(tmp)
+		return 0, fmt.Errorf("failed to write batch: %v", err)
+	}
+	q.next++
+	q.seqs = append(q.seqs, seq)
+	q.sizes = append(q.sizes, int64(len(batch)))
+	q.bytes += int64(len(batch))
+
+	dropped := 0
+	for q.bytes > q.maxBytes && len(q.seqs) > 1 {
+		if err := q.pop(); err != nil {
+			return dropped, err
+		}
+		dropped++
+	}
+	return dropped, nil
+}
+
+func (q *diskQueue) peek() ([]byte, error) {
+	if len(q.seqs) == 0 {
+		return nil, nil
+	}
+	batch, err := os.ReadFile(q.path(q.seqs[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch: %v", err)
+	}
+	return batch, nil
+}
+
+func (q *diskQueue) pop() error {
+	if len(q.seqs) == 0 {
+		return nil
+	}
+	if err := os.Remove(q.path(q.seqs[0])); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove batch: %v", err)
+	}
+	q.bytes -= q.sizes[0]
+	q.seqs = q.seqs[1:]
+	q.sizes = q.sizes[1:]
+	return nil
+}
+
+func (q *diskQueue) size() (int, int64) {
+	return len(q.seqs), q.bytes
+}
@@ -0,0 +1,96 @@
+package remotewrite
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemQueue_DropsOldest(t *testing.T) {
+	q := newMemQueue(10)
+	for _, b := range []string{"aaaa", "bbbb", "cccc"} {
+		if _, err := q.push([]byte(b)); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+
+	if batches, n := q.size(); batches != 2 || n != 8 {
+		t.Errorf("Expected 2 batches of 8 bytes, got %d of %d", batches, n)
+	}
+	if batch, _ := q.peek(); string(batch) != "bbbb" {
+		t.Errorf("Expected oldest batch to be dropped, got %q first", batch)
+	}
+}
+
+func TestDiskQueue_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir, 10)
+	if err != nil {
+		t.Fatalf("openDiskQueue failed: %v", err)
+	}
+	for _, b := range []string{"aaaa", "bbbb", "cccc"} {
+		if _, err := q.push([]byte(b)); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+	if err := q.pop(); err != nil {
+		t.Fatalf("pop failed: %v", err)
+	}
+
+	reopened, err := openDiskQueue(dir, 10)
+	if err != nil {
+		t.Fatalf("openDiskQueue failed: %v", err)
+	}
+	if batches, n := reopened.size(); batches != 1 || n != 4 {
+		t.Errorf("Expected 1 batch of 4 bytes, got %d of %d", batches, n)
+	}
+	batch, err := reopened.peek()
+	if err != nil || !bytes.Equal(batch, []byte("cccc")) {
+		t.Errorf("Expected batch cccc, got %q (%v)", batch, err)
+	}
+
+	// New batches sort after the ones left behind
+	if _, err := reopened.push([]byte("dddd")); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if err := reopened.pop(); err != nil {
+		t.Fatalf("pop failed: %v", err)
+	}
+	if batch, _ := reopened.peek(); string(batch) != "dddd" {
+		t.Errorf("Expected batch dddd, got %q", batch)
+	}
+}
+
+func TestDiskQueue_LeavesTempFilesUntilRemoved(t *testing.T) {
+	dir := t.TempDir()
+	q, err := openDiskQueue(dir, 100)
+	if err != nil {
+		t.Fatalf("openDiskQueue failed: %v", err)
+	}
+	// Another writer mid-push
+	tmp := q.path(7) + ".tmp"
+	if err := os.WriteFile(tmp, []byte("half"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := openDiskQueue(dir, 100); err != nil {
+		t.Fatalf("openDiskQueue failed: %v", err)
+	}
+	if err := q.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if _, err := os.Stat(tmp); err != nil {
+		t.Fatalf("Expected open and reload to leave %s, got %v", filepath.Base(tmp), err)
+	}
+	if batches, _ := q.size(); batches != 0 {
+		t.Errorf("Expected the partial batch not to be queued, got %d batches", batches)
+	}
+
+	if err := q.removeTemp(); err != nil {
+		t.Fatalf("removeTemp failed: %v", err)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("Expected removeTemp to delete %s, got %v", filepath.Base(tmp), err)
+	}
+}
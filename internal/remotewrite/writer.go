@@ -0,0 +1,267 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/config"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errRejected marks a batch the endpoint will never accept, such as samples
+// that are too old. It is dropped instead of retried.
+var errRejected = errors.New("rejected")
+
+// Writer periodically gathers metrics and pushes them to a remote_write endpoint
+type Writer struct {
+	cfg      config.RemoteWrite
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	logger   *slog.Logger
+	queue    queue // Only touched by the Start goroutine once started
+	failing  bool  // Whether the last send failed, to log once per outage
+
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewWriter creates a writer pushing what gatherer returns. With a queue
+// directory configured, batches left by a previous run are sent first.
+func NewWriter(cfg config.RemoteWrite, gatherer prometheus.Gatherer, logger *slog.Logger) (*Writer, error) {
+	var q queue = newMemQueue(cfg.QueueMaxBytes)
+	if cfg.QueueDir != "" {
+		dq, err := openDiskQueue(cfg.QueueDir, cfg.QueueMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		q = dq
+	}
+
+	return &Writer{
+		cfg:       cfg,
+		gatherer:  gatherer,
+		client:    &http.Client{Timeout: cfg.Timeout},
+		logger:    logger.With("component", "remote_write"),
+		queue:     q,
+		stopCh:    make(chan struct{}),
+		stoppedCh: make(chan struct{}),
+	}, nil
+}
+
+// InheritState takes over the unsent batches of a stopped writer so a reload
+// doesn't lose them. In-memory batches are moved over; a disk queue is rescanned
+// for batches prev wrote after this writer opened it.
+func (w *Writer) InheritState(prev *Writer) {
+	if dq, ok := w.queue.(*diskQueue); ok {
+		if err := dq.reload(); err != nil {
+			w.logger.Error("Failed to reload queue", "error", err)
+		}
+		w.updateQueueMetrics()
+		return
+	}
+
+	mq, ok := w.queue.(*memQueue)
+	prevQ, prevOK := prev.queue.(*memQueue)
+	if !ok || !prevOK {
+		return
+	}
+	mq.batches = append(prevQ.batches, mq.batches...)
+	mq.bytes += prevQ.bytes
+	if dropped := mq.trim(); dropped > 0 {
+		batchesDropped.WithLabelValues("queue_full").Add(float64(dropped))
+	}
+	prevQ.batches, prevQ.bytes = nil, 0
+	w.updateQueueMetrics()
+}
+
+// Start pushes metrics every interval until ctx is cancelled or Stop is called
+func (w *Writer) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	defer close(w.stoppedCh)
+
+	w.logger.Info("Remote write started", "url", w.cfg.URL, "interval", w.cfg.Interval, "queue_dir", w.cfg.QueueDir)
+	// The previous writer has stopped by now, so half-written batches are its
+	// leftovers rather than a push in progress
+	if dq, ok := w.queue.(*diskQueue); ok {
+		if err := dq.removeTemp(); err != nil {
+			w.logger.Warn("Failed to clean up queue directory", "error", err)
+		}
+	}
+	w.updateQueueMetrics()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Remote write stopping")
+			return
+		case <-w.stopCh:
+			w.logger.Info("Remote write stopping")
+			return
+		case <-ticker.C:
+			w.enqueue(time.Now())
+			w.flush(ctx)
+		}
+	}
+}
+
+// Stop stops the writer (safe to call multiple times). Unsent batches stay queued.
+func (w *Writer) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+	<-w.stoppedCh
+}
+
+// enqueue gathers the current metrics and queues them as one batch
+func (w *Writer) enqueue(now time.Time) {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		// Gather returns whatever it could collect alongside the error
+		w.logger.Debug("Failed to gather some metrics", "error", err)
+	}
+	if len(families) == 0 {
+		return
+	}
+
+	batch := snappy.Encode(nil, Encode(families, now))
+	dropped, err := w.queue.push(batch)
+	if err != nil {
+		w.logger.Error("Failed to queue batch", "error", err)
+	}
+	if dropped > 0 {
+		batchesDropped.WithLabelValues("queue_full").Add(float64(dropped))
+		w.logger.Warn("Remote write queue full, dropped oldest batches", "dropped", dropped)
+	}
+	w.updateQueueMetrics()
+}
+
+// flush sends queued batches oldest first until the queue is empty or a send
+// fails with a retryable error, which is retried on the next tick
+func (w *Writer) flush(ctx context.Context) {
+	defer w.updateQueueMetrics()
+
+	for ctx.Err() == nil {
+		batch, err := w.queue.peek()
+		if err != nil {
+			w.logger.Error("Dropping unreadable batch", "error", err)
+			batchesDropped.WithLabelValues("corrupt").Inc()
+			if err := w.queue.pop(); err != nil {
+				w.logger.Error("Failed to remove batch", "error", err)
+				return
+			}
+			continue
+		}
+		if batch == nil {
+			return
+		}
+
+		err = w.send(ctx, batch)
+		switch {
+		case err == nil:
+			batchesSent.Inc()
+			lastSuccess.SetToCurrentTime()
+			if w.failing {
+				w.logger.Info("Remote write recovered")
+				w.failing = false
+			}
+		case errors.Is(err, errRejected):
+			batchesDropped.WithLabelValues("rejected").Inc()
+			w.logger.Error("Remote write endpoint rejected batch, dropping it", "error", err)
+		default:
+			requestFailures.Inc()
+			if !w.failing {
+				batches, _ := w.queue.size()
+				w.logger.Warn("Remote write failed, will retry", "error", err, "queued", batches)
+				w.failing = true
+			} else {
+				w.logger.Debug("Remote write failed, will retry", "error", err)
+			}
+			return
+		}
+
+		if err := w.queue.pop(); err != nil {
+			w.logger.Error("Failed to remove batch", "error", err)
+			return
+		}
+	}
+}
+
+// send posts one batch. Errors wrapping errRejected must not be retried.
+func (w *Writer) send(ctx context.Context, batch []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(batch))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errRejected, err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "starlink_exporter")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if err := w.authorize(req); err != nil {
+		return err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("server returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	default:
+		return fmt.Errorf("%w: server returned %s: %s", errRejected, resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// authorize adds the configured credentials to req, reading secret files fresh
+// so they can be rotated
+func (w *Writer) authorize(req *http.Request) error {
+	if ba := w.cfg.BasicAuth; ba.Username != "" {
+		password := ba.Password
+		if ba.PasswordFile != "" {
+			data, err := os.ReadFile(ba.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("failed to read password file: %v", err)
+			}
+			password = strings.TrimSpace(string(data))
+		}
+		req.SetBasicAuth(ba.Username, password)
+		return nil
+	}
+
+	token := w.cfg.BearerToken
+	if w.cfg.BearerTokenFile != "" {
+		data, err := os.ReadFile(w.cfg.BearerTokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read bearer token file: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// updateQueueMetrics publishes the queue size
+func (w *Writer) updateQueueMetrics() {
+	batches, n := w.queue.size()
+	queueBatches.Set(float64(batches))
+	queueBytes.Set(float64(n))
+}
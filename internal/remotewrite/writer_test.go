@@ -0,0 +1,86 @@
+package remotewrite
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/R167/starlink_exporter/internal/config"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestWriter(t *testing.T, url string) *Writer {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "h"})
+	gauge.Set(1)
+	reg.MustRegister(gauge)
+
+	cfg := config.Default().RemoteWrite
+	cfg.URL = url
+	cfg.BearerToken = "secret"
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+
+	w, err := NewWriter(cfg, reg, logger)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	return w
+}
+
+func TestWriter_Send(t *testing.T) {
+	var got http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		compressed, _ := io.ReadAll(r.Body)
+		body, _ = snappy.Decode(nil, compressed)
+	}))
+	defer srv.Close()
+
+	w := newTestWriter(t, srv.URL)
+	w.enqueue(time.UnixMilli(1000))
+	w.flush(context.Background())
+
+	if batches, _ := w.queue.size(); batches != 0 {
+		t.Errorf("Expected queue to be drained, got %d batches", batches)
+	}
+	if got.Get("Content-Encoding") != "snappy" || got.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" {
+		t.Errorf("Missing remote_write headers: %v", got)
+	}
+	if got.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Expected bearer token, got %q", got.Get("Authorization"))
+	}
+	if series := decodeSeries(t, body); len(series) != 1 || series[0] != "__name__=test_gauge 1@1000" {
+		t.Errorf("Unexpected series: %v", series)
+	}
+}
+
+func TestWriter_RetriesAndDrops(t *testing.T) {
+	code := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	w := newTestWriter(t, srv.URL)
+	w.enqueue(time.Now())
+	w.enqueue(time.Now())
+	w.flush(context.Background())
+	if batches, _ := w.queue.size(); batches != 2 {
+		t.Fatalf("Expected batches to stay queued after a 503, got %d", batches)
+	}
+
+	// Batches the endpoint rejects are dropped rather than retried forever
+	code = http.StatusBadRequest
+	w.flush(context.Background())
+	if batches, _ := w.queue.size(); batches != 0 {
+		t.Errorf("Expected rejected batches to be dropped, got %d", batches)
+	}
+}